@@ -0,0 +1,199 @@
+// Command dlq inspects and manages the dead-letter queue of a worker queue.
+//
+//	dlq [-queue name] list [-limit n] [-offset n]
+//	dlq [-queue name] inspect <id>
+//	dlq [-queue name] requeue [-all] [-id a,b] [-type t] [-error text] [-older-than d]
+//	dlq [-queue name] purge [-all] [-id a,b] [-type t] [-error text] [-older-than d]
+package main
+
+import (
+	"alerts-worker/internal/config"
+	"alerts-worker/internal/constants"
+	"alerts-worker/pkg/queue"
+	"alerts-worker/pkg/worker"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+)
+
+func main() {
+	queueName := flag.String("queue", string(constants.BinanceMarkPriceAlertsQueue), "queue whose dead letters to manage")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	appBase := config.New(
+		config.Init(),
+		config.WithDependencyInjector(),
+	)
+
+	redisClient := do.MustInvokeNamed[*redis.Client](appBase.Injector, "BinanceMarkPriceAlerts")
+	defer redisClient.Close()
+
+	backend, err := queue.NewRedisBackend(redisClient, *queueName, appBase.Config.WorkerBackend, queue.Options{
+		ConsumerGroup: appBase.Config.WorkerConsumerGroup,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	dlq := worker.NewDeadLetterQueue(redisClient, *queueName, backend)
+	ctx := context.Background()
+
+	switch command, args := flag.Arg(0), flag.Args()[1:]; command {
+	case "list":
+		err = list(ctx, dlq, args)
+	case "inspect":
+		err = inspect(ctx, dlq, args)
+	case "requeue":
+		err = apply(args, "requeue", func(filter *worker.DeadLetterFilter) (int, error) {
+			return dlq.Requeue(ctx, filter)
+		})
+	case "purge":
+		err = apply(args, "purge", func(filter *worker.DeadLetterFilter) (int, error) {
+			return dlq.Purge(ctx, filter)
+		})
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: dlq [-queue name] <command> [flags]
+
+commands:
+  list      list dead letters, newest first
+  inspect   show a dead letter with its payload
+  requeue   push matching dead letters back onto the queue
+  purge     delete matching dead letters
+
+`)
+	flag.PrintDefaults()
+}
+
+func list(ctx context.Context, dlq *worker.DeadLetterQueue, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := fs.Int64("limit", 50, "number of entries to show")
+	offset := fs.Int64("offset", 0, "number of newest entries to skip")
+	_ = fs.Parse(args)
+
+	total, err := dlq.Len(ctx)
+	if err != nil {
+		return err
+	}
+
+	letters, err := dlq.List(ctx, *offset, *limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFAILED AT\tEVENT TYPE\tERROR TYPE\tATTEMPTS\tERROR")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			letter.ID,
+			letter.FailedAt.Format(time.RFC3339),
+			letter.EventType,
+			letter.ErrorType,
+			letter.Attempts,
+			truncate(letter.Error, 80),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d dead letters\n", len(letters), total)
+	return nil
+}
+
+func inspect(ctx context.Context, dlq *worker.DeadLetterQueue, args []string) error {
+	if len(args) != 1 {
+		return errors.New("inspect takes exactly one dead letter ID")
+	}
+
+	letter, err := dlq.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	// Show the payload as JSON rather than an escaped string when it is valid JSON
+	var payload interface{} = letter.Payload
+	if json.Valid([]byte(letter.Payload)) {
+		payload = json.RawMessage(letter.Payload)
+	}
+
+	out, err := json.MarshalIndent(struct {
+		*worker.DeadLetter
+		Payload interface{} `json:"payload"`
+	}{letter, payload}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// apply parses the shared filter flags of requeue and purge. A filter or -all is required
+// so that a bare command never touches the whole queue by accident.
+func apply(args []string, name string, fn func(*worker.DeadLetterFilter) (int, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	all := fs.Bool("all", false, "match every dead letter")
+	ids := fs.String("id", "", "comma separated dead letter IDs")
+	eventType := fs.String("type", "", "match this event type")
+	errorText := fs.String("error", "", "match errors containing this text")
+	olderThan := fs.Duration("older-than", 0, "match dead letters that failed longer ago than this")
+	_ = fs.Parse(args)
+
+	filter := &worker.DeadLetterFilter{
+		EventType:     *eventType,
+		ErrorContains: *errorText,
+	}
+	if *ids != "" {
+		filter.IDs = strings.Split(*ids, ",")
+	}
+	if *olderThan > 0 {
+		filter.FailedBefore = time.Now().Add(-*olderThan)
+	}
+
+	if !*all && len(filter.IDs) == 0 && filter.EventType == "" && filter.ErrorContains == "" && filter.FailedBefore.IsZero() {
+		return fmt.Errorf("%s needs -all or at least one filter", name)
+	}
+
+	count, err := fn(filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%sd %d dead letters\n", name, count)
+	return nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -4,8 +4,11 @@ import (
 	"alerts-worker/internal/config"
 	"alerts-worker/internal/constants"
 	"alerts-worker/internal/event_handler"
+	"alerts-worker/internal/notifier"
 	"alerts-worker/internal/service"
+	"alerts-worker/internal/telegram_bot"
 	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/queue"
 	"alerts-worker/pkg/worker"
 	"context"
 	"errors"
@@ -38,6 +41,7 @@ func main() {
 	}
 
 	redisClient := do.MustInvokeNamed[*redis.Client](appBase.Injector, "BinanceMarkPriceAlerts")
+	notificationsRedisClient := do.MustInvokeNamed[*redis.Client](appBase.Injector, "Notifications")
 	logger := do.MustInvoke[*zerolog.Logger](appBase.Injector)
 
 	// Configure retry behavior
@@ -62,19 +66,89 @@ func main() {
 	workerMetrics := do.MustInvoke[*metrics.WorkerMetrics](appBase.Injector)
 
 	handlerOpts := &event_handler.EventHandlerOptions{
-		RetryConfig: retryConfig,
+		RetryConfig:        retryConfig,
+		ConflateMarkPrices: appBase.Config.WorkerConflatePrices,
+		Metrics:            do.MustInvoke[*metrics.EventHandlerMetrics](appBase.Injector),
 	}
 
 	eventHandler := event_handler.NewEventHandler(svc, logger, handlerOpts)
 
-	klinesSyncWorker := worker.NewWorker(redisClient, string(constants.BinanceMarkPriceAlertsQueue), eventHandler.HandleEvent, &worker.WorkerOptions{
-		WorkerCount: 600,
-	}, workerMetrics)
+	queueBackend := do.MustInvokeNamed[queue.Backend](appBase.Injector, "BinanceMarkPriceAlertsBackend")
+
+	workerOpts := &worker.WorkerOptions{
+		WorkerCount:   appBase.Config.WorkerMaxCount,
+		InstanceID:    appBase.Config.WorkerInstanceID,
+		MaxDeliveries: appBase.Config.WorkerMaxDeliveries,
+		Logger:        logger,
+		DeadLetters:   worker.NewDeadLetterQueue(redisClient, string(constants.BinanceMarkPriceAlertsQueue), queueBackend),
+	}
+	switch {
+	case appBase.Config.WorkerBatchSize > 1:
+		// Batches take many events per round trip, so far fewer workers are needed
+		workerOpts.WorkerCount = appBase.Config.WorkerBatchWorkers
+		workerOpts.BatchSize = appBase.Config.WorkerBatchSize
+		workerOpts.BatchHandler = eventHandler.HandleBatch
+	case appBase.Config.WorkerLanes > 0:
+		// A single receiver hands events to the lanes in queue order
+		workerOpts.WorkerCount = 1
+		workerOpts.Lanes = appBase.Config.WorkerLanes
+		workerOpts.LaneKey = event_handler.SymbolLaneKey
+	case appBase.Config.WorkerMinCount < appBase.Config.WorkerMaxCount:
+		workerOpts.Autoscale = &worker.AutoscaleOptions{
+			MinWorkers:        appBase.Config.WorkerMinCount,
+			MaxWorkers:        appBase.Config.WorkerMaxCount,
+			ScaleUpCooldown:   appBase.Config.WorkerScaleUpCooldownDuration(),
+			ScaleDownCooldown: appBase.Config.WorkerScaleDownCooldownDuration(),
+		}
+	}
+	// Batches and lanes run a fixed pool, so the bounds would silently be ignored
+	if workerOpts.Autoscale == nil && appBase.Config.WorkerMinCount < appBase.Config.WorkerMaxCount {
+		log.Warn().
+			Int("min_workers", appBase.Config.WorkerMinCount).
+			Int("max_workers", appBase.Config.WorkerMaxCount).
+			Msg("worker autoscaling only applies without batches or lanes, running a fixed pool")
+	}
+
+	klinesSyncWorker := worker.NewWorker(queueBackend, string(constants.BinanceMarkPriceAlertsQueue), eventHandler.HandleEvent, workerOpts, workerMetrics)
 
 	if err := klinesSyncWorker.Start(ctx); err != nil {
 		log.Fatal().Err(err)
 	}
 
+	outboxDispatcher := do.MustInvoke[*notifier.Dispatcher](appBase.Injector)
+	if err := outboxDispatcher.Start(ctx); err != nil {
+		log.Fatal().Err(err)
+	}
+
+	escalator := do.MustInvoke[*service.Escalator](appBase.Injector)
+	if err := escalator.Start(ctx); err != nil {
+		log.Fatal().Err(err)
+	}
+
+	telegramBot := do.MustInvoke[*telegram_bot.Bot](appBase.Injector)
+	var webhookServer *http.Server
+
+	switch {
+	case appBase.Config.TelegramBotToken == "":
+		log.Info().Msg("TELEGRAM_BOT_TOKEN is not set, not consuming telegram updates")
+	case appBase.Config.TelegramUpdatesMode == constants.TelegramUpdatesPolling:
+		if err := telegramBot.Start(ctx); err != nil {
+			log.Fatal().Err(err)
+		}
+	case appBase.Config.TelegramUpdatesMode == constants.TelegramUpdatesWebhook:
+		mux := http.NewServeMux()
+		mux.Handle(constants.TelegramWebhookPath, telegramBot)
+		webhookServer = &http.Server{
+			Addr:    appBase.Config.ServerHost + ":" + appBase.Config.ServerPort,
+			Handler: mux,
+		}
+		go func() {
+			if err := webhookServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error().Err(err).Msg("Telegram webhook server error")
+			}
+		}()
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -86,6 +160,15 @@ func main() {
 
 	eventHandler.Stop()
 	klinesSyncWorker.Stop(10 * time.Second)
+	telegramBot.Stop(10 * time.Second)
+	escalator.Stop(10 * time.Second)
+	outboxDispatcher.Stop(10 * time.Second)
+
+	if webhookServer != nil {
+		if err := webhookServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error shutting down telegram webhook server")
+		}
+	}
 
 	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
 		log.Error().Err(err).Msg("Error shutting down metrics server")
@@ -95,5 +178,9 @@ func main() {
 		log.Error().Err(err).Msg("Error closing Redis client")
 	}
 
+	if err := notificationsRedisClient.Close(); err != nil {
+		log.Error().Err(err).Msg("Error closing notifications Redis client")
+	}
+
 	cancel()
 }
@@ -0,0 +1,80 @@
+// Command templates checks the notification templates stored in the database.
+//
+//	templates validate
+package main
+
+import (
+	"alerts-worker/internal/config"
+	"alerts-worker/internal/notifier"
+	"alerts-worker/internal/repository"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/samber/do"
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 || flag.Arg(0) != "validate" {
+		usage()
+		os.Exit(2)
+	}
+
+	appBase := config.New(
+		config.Init(),
+		config.WithDependencyInjector(),
+	)
+
+	repo := do.MustInvoke[*repository.Repository](appBase.Injector)
+
+	invalid, err := validate(context.Background(), repo.NotificationTemplates)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: templates <command>
+
+commands:
+  validate  check every stored template the way renders do, exiting 1 if any is
+            invalid; renders skip invalid templates for the English or default one
+
+`)
+	flag.PrintDefaults()
+}
+
+// validate prints the invalid templates and returns their number
+func validate(ctx context.Context, repo repository.NotificationTemplateRepository) (int, error) {
+	templates, err := repo.ListNotificationTemplates(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tALERT TYPE\tCHANNEL\tLOCALE\tERROR")
+
+	invalid := 0
+	for i := range templates {
+		tmpl := &templates[i]
+		if err := notifier.ValidateNotificationTemplate(tmpl); err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tmpl.ID, tmpl.AlertTypeID, tmpl.Channel, tmpl.Locale, err)
+			invalid++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return invalid, err
+	}
+
+	fmt.Printf("\n%d of %d templates invalid\n", invalid, len(templates))
+	return invalid, nil
+}
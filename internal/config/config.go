@@ -15,6 +15,7 @@ import (
 type Config struct {
 	Env                    string `env:"ENV" env-default:"development"`
 	BinanceMarkPricesRedis string `env:"BINANCE_MARK_PRICES_REDIS_URL"`
+	NotificationsRedis     string `env:"NOTIFICATIONS_REDIS_URL"`
 	PostgresqlUsername     string `env:"POSTGRESQL_USERNAME" env-default:"postgres"`
 	PostgresqlPassword     string `env:"POSTGRESQL_PASSWORD" env-default:"postgres"`
 	PostgresqlDatabaseName string `env:"POSTGRESQL_DATABASE_NAME" env-default:"postgres"`
@@ -25,12 +26,89 @@ type Config struct {
 	ServerPort             string `env:"SERVER_PORT" env-default:"3000"`
 	ServiceName            string `env:"SERVICE_NAME"`
 	HTTPTimeout            int32  `env:"HTTP_TIMEOUT" env-default:"175"`
+	SMTPHost               string `env:"SMTP_HOST"`
+	SMTPPort               string `env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername           string `env:"SMTP_USERNAME"`
+	SMTPPassword           string `env:"SMTP_PASSWORD"`
+	SMTPFrom               string `env:"SMTP_FROM"`
+	SMTPFallbackHost       string `env:"SMTP_FALLBACK_HOST"`
+	SMTPFallbackPort       string `env:"SMTP_FALLBACK_PORT" env-default:"587"`
+	SMTPFallbackUsername   string `env:"SMTP_FALLBACK_USERNAME"`
+	SMTPFallbackPassword   string `env:"SMTP_FALLBACK_PASSWORD"`
+	SMTPFallbackFrom       string `env:"SMTP_FALLBACK_FROM"`
+	TelegramBotToken       string `env:"TELEGRAM_BOT_TOKEN"`
+	TelegramAPIURL         string `env:"TELEGRAM_API_URL" env-default:"https://api.telegram.org"`
+	TelegramFallbackAPIURL string `env:"TELEGRAM_FALLBACK_API_URL"`
+	TelegramUpdatesMode    string `env:"TELEGRAM_UPDATES_MODE" env-default:"polling"`
+	TelegramWebhookSecret  string `env:"TELEGRAM_WEBHOOK_SECRET"`
+	FirebaseProjectID      string `env:"FIREBASE_PROJECT_ID"`
+	FirebaseCredentials    string `env:"FIREBASE_CREDENTIALS_FILE"`
+	FCMAPIURL              string `env:"FCM_API_URL" env-default:"https://fcm.googleapis.com"`
+	VAPIDPrivateKey        string `env:"VAPID_PRIVATE_KEY"`
+	VAPIDSubject           string `env:"VAPID_SUBJECT"`
+	SlackWebhookBaseURL    string `env:"SLACK_WEBHOOK_BASE_URL" env-default:"https://hooks.slack.com"`
+	DiscordWebhookBaseURL  string `env:"DISCORD_WEBHOOK_BASE_URL" env-default:"https://discord.com"`
+
+	NotificationRatePerMinute float64 `env:"NOTIFICATION_RATE_PER_MINUTE" env-default:"10"`
+	NotificationRateBurst     int     `env:"NOTIFICATION_RATE_BURST" env-default:"20"`
+	NotificationRateOverflow  string  `env:"NOTIFICATION_RATE_OVERFLOW" env-default:"summary"`
+
+	AlertTriggeredStream       string `env:"ALERT_TRIGGERED_STREAM" env-default:"alert-triggered"`
+	AlertTriggeredStreamMaxLen int64  `env:"ALERT_TRIGGERED_STREAM_MAX_LEN" env-default:"100000"`
+
+	WorkerBackend           string `env:"WORKER_BACKEND" env-default:"list"`
+	WorkerConsumerGroup     string `env:"WORKER_CONSUMER_GROUP" env-default:"alerts-worker"`
+	WorkerStreamMaxLen      int64  `env:"WORKER_STREAM_MAX_LEN" env-default:"0"`
+	WorkerStreamMaxAge      int32  `env:"WORKER_STREAM_MAX_AGE" env-default:"86400"`
+	WorkerInstanceID        string `env:"WORKER_INSTANCE_ID"`
+	WorkerReliable          bool   `env:"WORKER_RELIABLE" env-default:"true"`
+	WorkerVisibilityTimeout int32  `env:"WORKER_VISIBILITY_TIMEOUT" env-default:"120"`
+	WorkerMaxDeliveries     int    `env:"WORKER_MAX_DELIVERIES" env-default:"5"`
+	WorkerBatchSize         int    `env:"WORKER_BATCH_SIZE" env-default:"1"`
+	WorkerBatchWorkers      int    `env:"WORKER_BATCH_WORKERS" env-default:"16"`
+	WorkerConflatePrices    bool   `env:"WORKER_CONFLATE_PRICES" env-default:"true"`
+	WorkerLanes             int    `env:"WORKER_LANES" env-default:"0"`
+	WorkerMinCount          int    `env:"WORKER_MIN_COUNT" env-default:"600"`
+	WorkerMaxCount          int    `env:"WORKER_MAX_COUNT" env-default:"600"`
+	WorkerScaleUpCooldown   int32  `env:"WORKER_SCALE_UP_COOLDOWN" env-default:"30"`
+	WorkerScaleDownCooldown int32  `env:"WORKER_SCALE_DOWN_COOLDOWN" env-default:"120"`
+
+	CircuitFailureThreshold int   `env:"CIRCUIT_FAILURE_THRESHOLD" env-default:"5"`
+	CircuitOpenTimeout      int32 `env:"CIRCUIT_OPEN_TIMEOUT" env-default:"30"`
 }
 
 func (c *Config) HTTPTimeoutDuration() time.Duration {
 	return time.Duration(c.HTTPTimeout) * time.Second
 }
 
+func (c *Config) CircuitOpenTimeoutDuration() time.Duration {
+	return time.Duration(c.CircuitOpenTimeout) * time.Second
+}
+
+func (c *Config) WorkerVisibilityTimeoutDuration() time.Duration {
+	return time.Duration(c.WorkerVisibilityTimeout) * time.Second
+}
+
+func (c *Config) WorkerStreamMaxAgeDuration() time.Duration {
+	return time.Duration(c.WorkerStreamMaxAge) * time.Second
+}
+
+func (c *Config) WorkerScaleUpCooldownDuration() time.Duration {
+	return time.Duration(c.WorkerScaleUpCooldown) * time.Second
+}
+
+func (c *Config) WorkerScaleDownCooldownDuration() time.Duration {
+	return time.Duration(c.WorkerScaleDownCooldown) * time.Second
+}
+
+// NotificationsRedisURL falls back to the mark prices Redis when no dedicated instance is configured
+func (c *Config) NotificationsRedisURL() string {
+	if c.NotificationsRedis != "" {
+		return c.NotificationsRedis
+	}
+	return c.BinanceMarkPricesRedis
+}
+
 type goEnv struct {
 	GoMod string `json:"GOMOD"`
 }
@@ -2,10 +2,18 @@ package config
 
 import (
 	"alerts-worker/internal/constants"
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/notifier"
 	"alerts-worker/internal/repository"
 	"alerts-worker/internal/service"
+	"alerts-worker/internal/telegram_bot"
+	"alerts-worker/pkg/circuitbreaker"
+	"alerts-worker/pkg/delayqueue"
 	"alerts-worker/pkg/metrics"
 	"alerts-worker/pkg/queue"
+	"alerts-worker/pkg/ratelimit"
+	"alerts-worker/pkg/telegram"
+	"alerts-worker/pkg/webpush"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/redis/go-redis/v9"
@@ -16,6 +24,7 @@ import (
 	"time"
 
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/rs/zerolog"
@@ -90,23 +99,270 @@ func NewInjector(cfg *Config) *do.Injector {
 		return client, nil
 	})
 
+	do.ProvideNamed(injector, "Notifications", func(i *do.Injector) (*redis.Client, error) {
+		client := redis.NewClient(&redis.Options{
+			Addr:         cfg.NotificationsRedisURL(),
+			DialTimeout:  10 * time.Second,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			PoolSize:     100,
+			MaxRetries:   3,
+		})
+
+		return client, nil
+	})
+
 	do.Provide(injector, func(i *do.Injector) (*metrics.WorkerMetrics, error) {
 		workerMetrics := metrics.InitWorkerMetrics()
 
 		return workerMetrics, nil
 	})
 
-	do.ProvideNamed(injector, string(constants.BinanceMarkPriceAlertsQueue), func(i *do.Injector) (*queue.Queue, error) {
+	do.Provide(injector, func(i *do.Injector) (*metrics.EventHandlerMetrics, error) {
+		eventHandlerMetrics := metrics.InitEventHandlerMetrics()
+
+		return eventHandlerMetrics, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*metrics.NotificationMetrics, error) {
+		notificationMetrics := metrics.InitNotificationMetrics()
+
+		return notificationMetrics, nil
+	})
+
+	// Producers and workers of the queue share the backend so that both agree on its transport
+	do.ProvideNamed(injector, "BinanceMarkPriceAlertsBackend", func(i *do.Injector) (queue.Backend, error) {
 		redisClient := do.MustInvokeNamed[*redis.Client](i, "BinanceMarkPriceAlerts")
-		batchQueue := queue.NewQueue(redisClient, string(constants.BinanceMarkPriceAlertsQueue))
+
+		return queue.NewRedisBackend(redisClient, string(constants.BinanceMarkPriceAlertsQueue), cfg.WorkerBackend, queue.Options{
+			Reliable:          cfg.WorkerReliable,
+			InstanceID:        cfg.WorkerInstanceID,
+			VisibilityTimeout: cfg.WorkerVisibilityTimeoutDuration(),
+			ConsumerGroup:     cfg.WorkerConsumerGroup,
+			MaxLen:            cfg.WorkerStreamMaxLen,
+			MaxAge:            cfg.WorkerStreamMaxAgeDuration(),
+		})
+	})
+
+	do.ProvideNamed(injector, string(constants.BinanceMarkPriceAlertsQueue), func(i *do.Injector) (*queue.Queue, error) {
+		backend := do.MustInvokeNamed[queue.Backend](i, "BinanceMarkPriceAlertsBackend")
+		batchQueue := queue.NewQueueWithBackend(backend, string(constants.BinanceMarkPriceAlertsQueue))
 
 		return batchQueue, nil
 	})
 
+	do.Provide(injector, func(i *do.Injector) (notifier.Senders, error) {
+		notificationMetrics := do.MustInvoke[*metrics.NotificationMetrics](i)
+		repo := do.MustInvoke[*repository.Repository](i)
+		logger := do.MustInvoke[*zerolog.Logger](i)
+		providers := make(map[models.NotificationChannel][]notifier.Provider)
+
+		if cfg.SMTPHost != "" {
+			providers[models.NotificationChannelEmail] = append(providers[models.NotificationChannelEmail], notifier.Provider{
+				Name: "smtp-primary",
+				Sender: notifier.NewEmailSender(notifier.EmailConfig{
+					Host:     cfg.SMTPHost,
+					Port:     cfg.SMTPPort,
+					Username: cfg.SMTPUsername,
+					Password: cfg.SMTPPassword,
+					From:     cfg.SMTPFrom,
+				}),
+			})
+		}
+
+		if cfg.SMTPFallbackHost != "" {
+			from := cfg.SMTPFallbackFrom
+			if from == "" {
+				from = cfg.SMTPFrom
+			}
+			providers[models.NotificationChannelEmail] = append(providers[models.NotificationChannelEmail], notifier.Provider{
+				Name: "smtp-fallback",
+				Sender: notifier.NewEmailSender(notifier.EmailConfig{
+					Host:     cfg.SMTPFallbackHost,
+					Port:     cfg.SMTPFallbackPort,
+					Username: cfg.SMTPFallbackUsername,
+					Password: cfg.SMTPFallbackPassword,
+					From:     from,
+				}),
+			})
+		}
+
+		if cfg.TelegramBotToken != "" {
+			providers[models.NotificationChannelTelegram] = append(providers[models.NotificationChannelTelegram], notifier.Provider{
+				Name:   "telegram-primary",
+				Sender: notifier.NewTelegramSender(cfg.TelegramBotToken, cfg.TelegramAPIURL),
+			})
+
+			if cfg.TelegramFallbackAPIURL != "" {
+				providers[models.NotificationChannelTelegram] = append(providers[models.NotificationChannelTelegram], notifier.Provider{
+					Name:   "telegram-fallback",
+					Sender: notifier.NewTelegramSender(cfg.TelegramBotToken, cfg.TelegramFallbackAPIURL),
+				})
+			}
+		}
+
+		if cfg.FirebaseCredentials != "" {
+			pushSender, err := notifier.NewPushSender(cfg.FirebaseProjectID, cfg.FirebaseCredentials, cfg.FCMAPIURL)
+			if err != nil {
+				return nil, err
+			}
+			providers[models.NotificationChannelPush] = append(providers[models.NotificationChannelPush], notifier.Provider{
+				Name:   "fcm",
+				Sender: pushSender,
+			})
+		}
+
+		if cfg.VAPIDPrivateKey != "" {
+			vapid, err := webpush.NewVAPID(cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+			if err != nil {
+				return nil, err
+			}
+			providers[models.NotificationChannelWebPush] = append(providers[models.NotificationChannelWebPush], notifier.Provider{
+				Name:   "web-push",
+				Sender: notifier.NewWebPushSender(webpush.NewClient(vapid, 15*time.Second), repo.WebPushSubscriptions, logger),
+			})
+		}
+
+		notificationsRedis := do.MustInvokeNamed[*redis.Client](i, "Notifications")
+		providers[models.NotificationChannelInApp] = []notifier.Provider{{
+			Name:   "in-app",
+			Sender: notifier.NewInAppSender(repo.InAppNotifications, notificationsRedis, constants.InAppNotificationsChannel),
+		}}
+
+		slackSender, err := notifier.NewSlackSender(cfg.SlackWebhookBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		providers[models.NotificationChannelSlack] = []notifier.Provider{{Name: "slack-webhook", Sender: slackSender}}
+
+		discordSender, err := notifier.NewDiscordSender(cfg.DiscordWebhookBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		providers[models.NotificationChannelDiscord] = []notifier.Provider{{Name: "discord-webhook", Sender: discordSender}}
+
+		if cfg.AlertTriggeredStream != "" {
+			eventsRedis := do.MustInvokeNamed[*redis.Client](i, "BinanceMarkPriceAlerts")
+			providers[models.NotificationChannelEvents] = []notifier.Provider{{
+				Name:   "alert-triggered-stream",
+				Sender: notifier.NewStreamSender(queue.NewStream(eventsRedis, cfg.AlertTriggeredStream, cfg.AlertTriggeredStreamMaxLen)),
+			}}
+		}
+
+		breakerOpts := circuitbreaker.Options{
+			FailureThreshold: cfg.CircuitFailureThreshold,
+			OpenTimeout:      cfg.CircuitOpenTimeoutDuration(),
+			HalfOpenProbes:   1,
+		}
+
+		senders := notifier.Senders{}
+		for channel, channelProviders := range providers {
+			senders[channel] = notifier.NewFailoverSender(channel, channelProviders, breakerOpts, notificationMetrics)
+		}
+
+		// Push and telegram fan out to every device and chat the user registered
+		for _, channel := range []models.NotificationChannel{models.NotificationChannelPush, models.NotificationChannelTelegram} {
+			if sender, ok := senders[channel]; ok {
+				senders[channel] = notifier.NewEndpointSender(channel, sender, repo.NotificationEndpoints, logger)
+			}
+		}
+
+		return senders, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*notifier.Renderer, error) {
+		repo := do.MustInvoke[*repository.Repository](i)
+		notificationMetrics := do.MustInvoke[*metrics.NotificationMetrics](i)
+		logger := do.MustInvoke[*zerolog.Logger](i)
+
+		return notifier.NewRenderer(repo.NotificationTemplates, logger, notificationMetrics, time.Minute), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*notifier.RateLimiter, error) {
+		redisClient := do.MustInvokeNamed[*redis.Client](i, "Notifications")
+		repo := do.MustInvoke[*repository.Repository](i)
+
+		bucket := ratelimit.NewTokenBucket(redisClient, constants.NotificationRateLimitPrefix)
+
+		return notifier.NewRateLimiter(bucket, repo.Subscriptions, notifier.RateLimitOptions{
+			Default:      ratelimit.PerMinute(cfg.NotificationRatePerMinute, cfg.NotificationRateBurst),
+			Overflow:     notifier.OverflowMode(cfg.NotificationRateOverflow),
+			PlanCacheTTL: time.Minute,
+		}), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*notifier.Dispatcher, error) {
+		repo := do.MustInvoke[*repository.Repository](i)
+		senders := do.MustInvoke[notifier.Senders](i)
+		renderer := do.MustInvoke[*notifier.Renderer](i)
+		limiter := do.MustInvoke[*notifier.RateLimiter](i)
+		notificationMetrics := do.MustInvoke[*metrics.NotificationMetrics](i)
+		logger := do.MustInvoke[*zerolog.Logger](i)
+		redisClient := do.MustInvokeNamed[*redis.Client](i, "Notifications")
+
+		overflow := notifier.NewTriggerBuffer(redisClient, constants.NotificationOverflowPrefix)
+		digests := notifier.NewTriggerBuffer(redisClient, constants.NotificationDigestPrefix)
+
+		opts := &notifier.DispatcherOptions{
+			WorkerCount:  8,
+			BatchSize:    50,
+			PollInterval: 500 * time.Millisecond,
+			Lease:        2 * time.Minute,
+			SendTimeout:  30 * time.Second,
+			MaxAttempts:  8,
+			RetryBackoff: 5 * time.Second,
+			MaxBackoff:   15 * time.Minute,
+		}
+
+		return notifier.NewDispatcher(repo, senders, renderer, notificationMetrics, logger, opts,
+			notifier.WithRateLimiter(limiter, overflow),
+			notifier.WithDigester(notifier.NewDigester(digests, repo.NotificationDigests, time.Minute)),
+		), nil
+	})
+
 	do.Provide(injector, func(i *do.Injector) (service.AlertService, error) {
 		userRepo := do.MustInvoke[*repository.Repository](i)
+		redisClient := do.MustInvokeNamed[*redis.Client](i, "Notifications")
+
+		senders := do.MustInvoke[notifier.Senders](i)
+		renderer := do.MustInvoke[*notifier.Renderer](i)
+
+		escalations := delayqueue.NewDelayQueue(redisClient, constants.AlertEscalationsKey)
+		testResults := notifier.NewTestResultStore(redisClient, constants.NotificationTestPrefix, 10*time.Minute)
+
+		eventsRedis := do.MustInvokeNamed[*redis.Client](i, "BinanceMarkPriceAlerts")
+
+		options := []func(*service.Service){
+			service.WithTestNotifications(senders, renderer, testResults),
+			service.WithMarkPriceStore(eventsRedis, constants.MarkPriceLastKey),
+		}
+		if cfg.AlertTriggeredStream != "" {
+			options = append(options, service.WithTriggerEvents())
+		}
+
+		return service.New(userRepo, escalations, options...), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*telegram_bot.Bot, error) {
+		alertService := do.MustInvoke[service.AlertService](i)
+		logger := do.MustInvoke[*zerolog.Logger](i)
+
+		// Without a secret anyone could post updates to the webhook
+		if cfg.TelegramUpdatesMode == constants.TelegramUpdatesWebhook && cfg.TelegramWebhookSecret == "" {
+			return nil, errors.New("TELEGRAM_WEBHOOK_SECRET is required in webhook mode")
+		}
+
+		// The client timeout has to outlast the long polling timeout
+		client := telegram.NewClient(cfg.TelegramBotToken, cfg.TelegramAPIURL, 60*time.Second)
+
+		return telegram_bot.NewBot(client, alertService, logger, cfg.TelegramWebhookSecret), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*service.Escalator, error) {
+		alertService := do.MustInvoke[service.AlertService](i)
+		logger := do.MustInvoke[*zerolog.Logger](i)
 
-		return service.New(userRepo), nil
+		return service.NewEscalator(alertService, logger, time.Second, 100), nil
 	})
 
 	return injector
@@ -0,0 +1,14 @@
+package constants
+
+const (
+	TelegramUpdatesPolling = "polling"
+	TelegramUpdatesWebhook = "webhook"
+	TelegramWebhookPath    = "/telegram/webhook"
+
+	NotificationRateLimitPrefix = "notification-rate-limit"
+	NotificationOverflowPrefix  = "notification-overflow"
+	NotificationDigestPrefix    = "notification-digest"
+	AlertEscalationsKey         = "alert-escalations"
+	NotificationTestPrefix      = "notification-test"
+	InAppNotificationsChannel   = "in-app-notifications"
+)
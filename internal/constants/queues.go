@@ -5,3 +5,6 @@ const (
 )
 
 type BinanceQueue string
+
+// MarkPriceLastKey is the hash of the last evaluated mark price per symbol
+const MarkPriceLastKey = "binance-mark-prices:last"
@@ -0,0 +1,225 @@
+package event_handler
+
+import (
+	"alerts-worker/internal/events"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// errRoundReleased tells the callers waiting on a round that it was handed back without
+// being evaluated, so that they evaluate their own ticks again
+var errRoundReleased = errors.New("conflated round released")
+
+// conflatedRound holds the ticks of a symbol that arrived while it was being evaluated.
+// Only the newest tick and the ticks at the window high and low are kept, so crossings
+// in between still fire. Their callers wait for done and return err.
+type conflatedRound struct {
+	latest *events.BinanceMarkPriceEvent
+	high   *events.BinanceMarkPriceEvent
+	low    *events.BinanceMarkPriceEvent
+	ticks  int
+
+	done chan struct{}
+	err  error
+}
+
+func newConflatedRound() *conflatedRound {
+	return &conflatedRound{done: make(chan struct{})}
+}
+
+func (r *conflatedRound) add(price events.BinanceMarkPriceEvent) {
+	r.ticks++
+	if r.latest == nil || price.Timestamp >= r.latest.Timestamp {
+		r.latest = &price
+	}
+	if r.high == nil || price.Price > r.high.Price {
+		r.high = &price
+	}
+	if r.low == nil || price.Price < r.low.Price {
+		r.low = &price
+	}
+}
+
+// prices returns the kept ticks, without duplicates
+func (r *conflatedRound) prices() []events.BinanceMarkPriceEvent {
+	prices := []events.BinanceMarkPriceEvent{*r.low}
+	if r.high != r.low {
+		prices = append(prices, *r.high)
+	}
+	if r.latest != r.low && r.latest != r.high {
+		prices = append(prices, *r.latest)
+	}
+	return prices
+}
+
+func (r *conflatedRound) finish(err error) {
+	r.err = err
+	close(r.done)
+}
+
+// MarkPriceConflator sits in front of the mark price evaluation. A symbol is evaluated by
+// one caller at a time; ticks arriving meanwhile are conflated into a round that caller
+// evaluates once it is done, and their callers wait for the outcome of that round. When
+// the queue backs up this evaluates the newest price instead of every stale one in turn,
+// while every caller still only returns once its tick was evaluated.
+//
+// A caller evaluates at most one conflated round after its own prices and then hands the
+// symbols back, so a busy symbol can't keep it from returning. The callers of a round
+// conflated after that, or left when the evaluating caller's context ends, evaluate
+// their ticks again themselves.
+type MarkPriceConflator struct {
+	evaluate func(ctx context.Context, prices []events.BinanceMarkPriceEvent) error
+	logger   *zerolog.Logger
+
+	mu sync.Mutex
+	// busy has an entry for every symbol being evaluated, with the round conflated since
+	busy map[string]*conflatedRound
+}
+
+func NewMarkPriceConflator(evaluate func(ctx context.Context, prices []events.BinanceMarkPriceEvent) error, logger *zerolog.Logger) *MarkPriceConflator {
+	return &MarkPriceConflator{
+		evaluate: evaluate,
+		logger:   logger,
+		busy:     make(map[string]*conflatedRound),
+	}
+}
+
+// Evaluate evaluates prices of idle symbols and conflates those of busy ones, waiting
+// for the rounds they were conflated into. It returns the errors of its own evaluation
+// and of those rounds.
+func (c *MarkPriceConflator) Evaluate(ctx context.Context, prices []events.BinanceMarkPriceEvent) error {
+	var errs []error
+
+	for len(prices) > 0 {
+		claimed, own, joined := c.claim(prices)
+		if len(own) > 0 {
+			if err := c.evaluateClaimed(ctx, claimed, own); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		prices = nil
+		for round, roundPrices := range joined {
+			select {
+			case <-round.done:
+			case <-ctx.Done():
+				// Unacknowledged, the ticks are delivered again
+				return errors.Join(append(errs, ctx.Err())...)
+			}
+
+			if errors.Is(round.err, errRoundReleased) {
+				prices = append(prices, roundPrices...)
+				continue
+			}
+			if round.err != nil {
+				errs = append(errs, round.err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// claim takes the idle symbols of prices and conflates the prices of busy ones. It
+// returns the claimed symbols with their prices and the rounds joined with the prices
+// added to each.
+func (c *MarkPriceConflator) claim(prices []events.BinanceMarkPriceEvent) (
+	map[string]bool,
+	[]events.BinanceMarkPriceEvent,
+	map[*conflatedRound][]events.BinanceMarkPriceEvent) {
+
+	claimed := make(map[string]bool)
+	joined := make(map[*conflatedRound][]events.BinanceMarkPriceEvent)
+	var own []events.BinanceMarkPriceEvent
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, price := range prices {
+		if !claimed[price.Symbol] {
+			if round, ok := c.busy[price.Symbol]; ok {
+				round.add(price)
+				joined[round] = append(joined[round], price)
+				continue
+			}
+			c.busy[price.Symbol] = newConflatedRound()
+			claimed[price.Symbol] = true
+		}
+		own = append(own, price)
+	}
+
+	return claimed, own, joined
+}
+
+// evaluateClaimed evaluates the caller's own prices and one round of the ticks conflated
+// meanwhile, then hands the symbols back. It returns the error of the own prices; the
+// round's error goes to the callers waiting on it.
+func (c *MarkPriceConflator) evaluateClaimed(ctx context.Context, claimed map[string]bool, prices []events.BinanceMarkPriceEvent) error {
+	defer c.release(claimed)
+
+	err := c.evaluate(ctx, prices)
+	if ctx.Err() != nil {
+		return err
+	}
+
+	rounds, conflated := c.takeConflated(claimed)
+	if len(rounds) == 0 {
+		return err
+	}
+
+	roundErr := c.evaluate(ctx, conflated)
+	// Cut short by this caller's shutdown, the round is left to its own callers
+	if roundErr != nil && ctx.Err() != nil {
+		roundErr = errRoundReleased
+	}
+	for _, round := range rounds {
+		round.finish(roundErr)
+	}
+
+	return err
+}
+
+// takeConflated returns the rounds conflated for the claimed symbols and their ticks
+func (c *MarkPriceConflator) takeConflated(claimed map[string]bool) ([]*conflatedRound, []events.BinanceMarkPriceEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var rounds []*conflatedRound
+	var prices []events.BinanceMarkPriceEvent
+	for symbol := range claimed {
+		round := c.busy[symbol]
+		if round.ticks == 0 {
+			continue
+		}
+
+		c.logger.Debug().
+			Str("symbol", symbol).
+			Int("ticks", round.ticks).
+			Float64("high", round.high.Price).
+			Float64("low", round.low.Price).
+			Msg("evaluating conflated mark prices")
+
+		rounds = append(rounds, round)
+		prices = append(prices, round.prices()...)
+		c.busy[symbol] = newConflatedRound()
+	}
+
+	return rounds, prices
+}
+
+// release hands the claimed symbols back. The callers of rounds conflated since the last
+// evaluated one evaluate their ticks again.
+func (c *MarkPriceConflator) release(claimed map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for symbol := range claimed {
+		if round := c.busy[symbol]; round.ticks > 0 {
+			round.finish(errRoundReleased)
+		}
+		delete(c.busy, symbol)
+	}
+}
@@ -0,0 +1,197 @@
+package event_handler
+
+import (
+	"alerts-worker/internal/events"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// blockingEvaluator records evaluated prices. The first evaluation blocks until released,
+// so that further ticks are conflated meanwhile.
+type blockingEvaluator struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls [][]events.BinanceMarkPriceEvent
+	// fail returns the error of the n-th evaluation, counting from 0
+	fail func(call int) error
+}
+
+func newBlockingEvaluator() *blockingEvaluator {
+	return &blockingEvaluator{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		fail:    func(int) error { return nil },
+	}
+}
+
+func (e *blockingEvaluator) evaluate(ctx context.Context, prices []events.BinanceMarkPriceEvent) error {
+	e.mu.Lock()
+	call := len(e.calls)
+	e.calls = append(e.calls, prices)
+	e.mu.Unlock()
+
+	if call == 0 {
+		close(e.started)
+		select {
+		case <-e.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return e.fail(call)
+}
+
+func (e *blockingEvaluator) evaluated() [][]events.BinanceMarkPriceEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([][]events.BinanceMarkPriceEvent{}, e.calls...)
+}
+
+func tick(price float64, timestamp int64) events.BinanceMarkPriceEvent {
+	return events.BinanceMarkPriceEvent{Symbol: "BTCUSDT", Price: price, Timestamp: timestamp}
+}
+
+// conflate starts an owner evaluating the first tick and conflates the other ticks while
+// it is blocked. It returns the results of the owner and of every conflated caller.
+func conflate(t *testing.T, ctx context.Context, c *MarkPriceConflator, e *blockingEvaluator, first events.BinanceMarkPriceEvent, conflated ...events.BinanceMarkPriceEvent) (chan error, []chan error) {
+	t.Helper()
+
+	owner := make(chan error, 1)
+	go func() { owner <- c.Evaluate(ctx, []events.BinanceMarkPriceEvent{first}) }()
+	<-e.started
+
+	waiters := make([]chan error, len(conflated))
+	for i, price := range conflated {
+		waiters[i] = make(chan error, 1)
+		go func(result chan error, price events.BinanceMarkPriceEvent) {
+			result <- c.Evaluate(context.Background(), []events.BinanceMarkPriceEvent{price})
+		}(waiters[i], price)
+	}
+
+	// Conflated callers wait for the owner, so they can only be observed through the round
+	waitForTicks(t, c, "BTCUSDT", len(conflated))
+	return owner, waiters
+}
+
+func waitForTicks(t *testing.T, c *MarkPriceConflator, symbol string, ticks int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		round, ok := c.busy[symbol]
+		conflated := ok && round.ticks == ticks
+		c.mu.Unlock()
+
+		if conflated {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ticks were not conflated in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func result(t *testing.T, ch chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("caller did not return")
+		return nil
+	}
+}
+
+func TestConflatorEvaluatesConflatedExtremes(t *testing.T) {
+	e := newBlockingEvaluator()
+	logger := zerolog.Nop()
+	c := NewMarkPriceConflator(e.evaluate, &logger)
+
+	owner, waiters := conflate(t, context.Background(), c, e, tick(100, 1), tick(90, 2), tick(120, 3), tick(105, 4))
+	close(e.release)
+
+	if err := result(t, owner); err != nil {
+		t.Fatalf("owner: %v", err)
+	}
+	for i, waiter := range waiters {
+		if err := result(t, waiter); err != nil {
+			t.Fatalf("conflated caller %d: %v", i, err)
+		}
+	}
+
+	calls := e.evaluated()
+	if len(calls) != 2 {
+		t.Fatalf("expected the own tick and one conflated round, got %d evaluations", len(calls))
+	}
+	got := map[float64]bool{}
+	for _, price := range calls[1] {
+		got[price.Price] = true
+	}
+	if len(calls[1]) != 3 || !got[90] || !got[120] || !got[105] {
+		t.Fatalf("expected the low, high and latest tick, got %v", calls[1])
+	}
+}
+
+func TestConflatorReturnsFailedRoundToConflatedCallers(t *testing.T) {
+	e := newBlockingEvaluator()
+	roundErr := errors.New("round failed")
+	e.fail = func(call int) error {
+		if call == 1 {
+			return roundErr
+		}
+		return nil
+	}
+	logger := zerolog.Nop()
+	c := NewMarkPriceConflator(e.evaluate, &logger)
+
+	owner, waiters := conflate(t, context.Background(), c, e, tick(100, 1), tick(90, 2), tick(120, 3))
+	close(e.release)
+
+	// The owner's own tick was evaluated, so its message may be acknowledged
+	if err := result(t, owner); err != nil {
+		t.Fatalf("owner: %v", err)
+	}
+	// The conflated ticks were not, so their messages must be delivered again
+	for i, waiter := range waiters {
+		if err := result(t, waiter); !errors.Is(err, roundErr) {
+			t.Fatalf("conflated caller %d: expected the round error, got %v", i, err)
+		}
+	}
+}
+
+func TestConflatorConflatedCallersEvaluateReleasedRounds(t *testing.T) {
+	e := newBlockingEvaluator()
+	logger := zerolog.Nop()
+	c := NewMarkPriceConflator(e.evaluate, &logger)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	owner, waiters := conflate(t, ctx, c, e, tick(100, 1), tick(90, 2))
+	// The owner shuts down before evaluating the conflated round
+	cancel()
+
+	if err := result(t, owner); !errors.Is(err, context.Canceled) {
+		t.Fatalf("owner: expected context.Canceled, got %v", err)
+	}
+	if err := result(t, waiters[0]); err != nil {
+		t.Fatalf("conflated caller: %v", err)
+	}
+
+	calls := e.evaluated()
+	if len(calls) != 2 {
+		t.Fatalf("expected the conflated tick to be evaluated by its caller, got %d evaluations", len(calls))
+	}
+	// The owner's tick is delivered again rather than kept, so it is not evaluated twice
+	if len(calls[1]) != 1 || calls[1][0].Price != 90 {
+		t.Fatalf("expected only the conflated tick, got %v", calls[1])
+	}
+}
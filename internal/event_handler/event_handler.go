@@ -3,7 +3,10 @@ package event_handler
 import (
 	"alerts-worker/internal/events"
 	"alerts-worker/internal/service"
+	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/worker"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"time"
@@ -21,10 +24,18 @@ type EventHandler struct {
 	logger       *zerolog.Logger
 	stopChan     chan struct{}
 	retryConfig  RetryConfig
+	// markPrices evaluates mark prices, through the conflator when enabled
+	markPrices func(ctx context.Context, prices []events.BinanceMarkPriceEvent) error
+	router     *Router
 }
 
 type EventHandlerOptions struct {
 	RetryConfig *RetryConfig
+	// ConflateMarkPrices evaluates only the newest tick, and the window high and low, of a
+	// symbol whose previous tick is still being evaluated
+	ConflateMarkPrices bool
+	// Metrics, when set, records handled and unknown events
+	Metrics *metrics.EventHandlerMetrics
 }
 
 func DefaultRetryConfig() RetryConfig {
@@ -63,60 +74,154 @@ func NewEventHandler(
 		logger:       logger,
 		stopChan:     make(chan struct{}),
 		retryConfig:  *opts.RetryConfig,
+		markPrices:   alertService.EvaluateMarkPrices,
 	}
 
+	if opts.ConflateMarkPrices {
+		handler.markPrices = NewMarkPriceConflator(alertService.EvaluateMarkPrices, logger).Evaluate
+	}
+
+	handler.router = NewRouter(logger, opts.Metrics)
+	handler.router.Use(TracingMiddleware(), LoggingMiddleware(logger))
+	if opts.Metrics != nil {
+		handler.router.Use(MetricsMiddleware(opts.Metrics))
+	}
+	handler.router.Use(RecoveryMiddleware(logger))
+	handler.registerRoutes()
+
 	return handler
 }
 
+// registerRoutes maps every event type to its service call
+func (h *EventHandler) registerRoutes() {
+	h.router.Handle(events.EventTypeBinanceMarkPrice, h.handleMarkPrices, RouteOptions{
+		Timeout: 10 * time.Second,
+		Retry:   &h.retryConfig,
+	})
+	h.router.Handle(events.EventTypeAlertAcknowledged, h.handleAlertAcknowledged, RouteOptions{
+		Timeout: 5 * time.Second,
+		Retry:   &h.retryConfig,
+	})
+	// Test notifications call external providers directly, so only a few run at once
+	h.router.Handle(events.EventTypeTestNotification, h.handleTestNotification, RouteOptions{
+		Timeout:     20 * time.Second,
+		Retry:       &h.retryConfig,
+		Concurrency: 8,
+	})
+}
+
 // HandleEvent processes an event received from the worker
 func (h *EventHandler) HandleEvent(ctx context.Context, event *events.Event) error {
-	return h.handleSyncEvent(ctx, event)
+	return h.router.Dispatch(ctx, event)
 }
 
-// handleSyncEvent processes a sync event
-func (h *EventHandler) handleSyncEvent(ctx context.Context, event *events.Event) error {
-	logger := h.logger.With().
-		Str("event_type", event.Type).
-		Time("event_created_at", event.CreatedAt).
-		Logger()
+// HandleBatch processes events received together from the worker. Mark prices are
+// evaluated as one snapshot, dispatched as a single event carrying all of them; each of
+// their events gets the error of its symbol, so only those of failed symbols are retried.
+// Other events are handled one by one.
+func (h *EventHandler) HandleBatch(ctx context.Context, batch []*events.Event) []error {
+	errs := make([]error, len(batch))
 
-	logger.Debug().
-		Interface("event_data", event).
-		Msg("processing sync event")
-
-	var lastErr error
-	backoff := h.retryConfig.InitialBackoff
-
-	for attempt := 0; attempt <= h.retryConfig.MaxRetries; attempt++ {
-		if attempt > 0 {
-			logger.Debug().
-				Int("attempt", attempt).
-				Dur("backoff", backoff).
-				Err(lastErr).
-				Msg("preparing retry attempt")
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-				// Continue after backoff period
-			}
+	var prices []events.BinanceMarkPriceEvent
+	var priceEvents []int
 
-			backoff = time.Duration(float64(backoff) * h.retryConfig.BackoffFactor)
-			if backoff > h.retryConfig.MaxBackoff {
-				backoff = h.retryConfig.MaxBackoff
-			}
+	for i, event := range batch {
+		if event.Type != events.EventTypeBinanceMarkPrice {
+			errs[i] = h.HandleEvent(ctx, event)
+			continue
 		}
 
-		switch event.Type {
-		case events.EventTypeBinanceMarkPrice:
+		var price events.BinanceMarkPriceEvent
+		if err := events.DecodeData(event, &price); err != nil {
+			errs[i] = worker.Permanent(fmt.Errorf("failed to decode %s event: %w", event.Type, err))
+			continue
+		}
+		prices = append(prices, price)
+		priceEvents = append(priceEvents, i)
+	}
 
+	if len(prices) > 0 {
+		err := h.router.Dispatch(ctx, &events.Event{
+			Type:      events.EventTypeBinanceMarkPrice,
+			Data:      prices,
+			CreatedAt: time.Now(),
+		})
+		for j, i := range priceEvents {
+			errs[i] = symbolError(err, prices[j].Symbol)
 		}
+	}
 
+	return errs
+}
+
+// symbolError returns the part of err concerning symbol. Errors that are not per symbol,
+// such as a failed alert query, concern every symbol.
+func symbolError(err error, symbol string) error {
+	switch e := err.(type) {
+	case nil:
 		return nil
+	case service.SymbolErrors:
+		return e[symbol]
+	case interface{ Unwrap() []error }:
+		// Joined by the conflator, from the caller's own evaluation and conflated rounds
+		var errs []error
+		for _, child := range e.Unwrap() {
+			if err := symbolError(child, symbol); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	case interface{ Unwrap() error }:
+		var symbolErrs service.SymbolErrors
+		if !errors.As(err, &symbolErrs) {
+			return err
+		}
+		return symbolError(e.Unwrap(), symbol)
+	}
+	return err
+}
+
+// SymbolLaneKey keys mark price events by symbol so that the worker evaluates the ticks
+// of a symbol in order
+func SymbolLaneKey(event *events.Event) string {
+	if event.Type != events.EventTypeBinanceMarkPrice {
+		return ""
+	}
+	data, ok := event.Data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	symbol, _ := data["symbol"].(string)
+	return symbol
+}
+
+// handleMarkPrices evaluates a single tick, or the snapshot of a batch
+func (h *EventHandler) handleMarkPrices(ctx context.Context, event *events.Event) error {
+	if prices, ok := event.Data.([]events.BinanceMarkPriceEvent); ok {
+		return h.markPrices(ctx, prices)
 	}
 
-	return fmt.Errorf("failed after %d retries: %v", h.retryConfig.MaxRetries, lastErr)
+	var price events.BinanceMarkPriceEvent
+	if err := events.DecodeData(event, &price); err != nil {
+		return worker.Permanent(fmt.Errorf("failed to decode %s event: %w", event.Type, err))
+	}
+	return h.markPrices(ctx, []events.BinanceMarkPriceEvent{price})
+}
+
+func (h *EventHandler) handleAlertAcknowledged(ctx context.Context, event *events.Event) error {
+	var ack events.AlertAcknowledgedEvent
+	if err := events.DecodeData(event, &ack); err != nil {
+		return worker.Permanent(fmt.Errorf("failed to decode %s event: %w", event.Type, err))
+	}
+	return h.alertService.AcknowledgeAlert(ctx, &ack)
+}
+
+func (h *EventHandler) handleTestNotification(ctx context.Context, event *events.Event) error {
+	var req events.TestNotificationEvent
+	if err := events.DecodeData(event, &req); err != nil {
+		return worker.Permanent(fmt.Errorf("failed to decode %s event: %w", event.Type, err))
+	}
+	return h.alertService.SendTestNotification(ctx, &req)
 }
 
 func (h *EventHandler) Stop() {
@@ -0,0 +1,75 @@
+package event_handler
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/service"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// markPriceService fails the evaluation of the symbols in failed
+type markPriceService struct {
+	service.AlertService
+	failed map[string]error
+}
+
+func (s *markPriceService) EvaluateMarkPrices(ctx context.Context, prices []events.BinanceMarkPriceEvent) error {
+	errs := make(service.SymbolErrors)
+	for _, price := range prices {
+		if err, ok := s.failed[price.Symbol]; ok {
+			errs[price.Symbol] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func markPriceEvent(symbol string) *events.Event {
+	return &events.Event{
+		Type: events.EventTypeBinanceMarkPrice,
+		Data: map[string]interface{}{"symbol": symbol, "price": 100.0},
+	}
+}
+
+func TestHandleBatchFailsOnlyTheEventsOfFailedSymbols(t *testing.T) {
+	symbolErr := errors.New("trigger failed")
+	alertService := &markPriceService{failed: map[string]error{"ETHUSDT": symbolErr}}
+	logger := zerolog.Nop()
+
+	for _, conflate := range []bool{false, true} {
+		h := NewEventHandler(alertService, &logger, &EventHandlerOptions{
+			RetryConfig:        &RetryConfig{},
+			ConflateMarkPrices: conflate,
+		})
+
+		errs := h.HandleBatch(context.Background(), []*events.Event{
+			markPriceEvent("BTCUSDT"),
+			markPriceEvent("ETHUSDT"),
+			markPriceEvent("BTCUSDT"),
+		})
+
+		if errs[0] != nil || errs[2] != nil {
+			t.Fatalf("conflate %v: expected the BTCUSDT events to succeed, got %v and %v", conflate, errs[0], errs[2])
+		}
+		if !errors.Is(errs[1], symbolErr) {
+			t.Fatalf("conflate %v: expected the ETHUSDT error, got %v", conflate, errs[1])
+		}
+	}
+}
+
+func TestSymbolErrorKeepsErrorsOfAllSymbols(t *testing.T) {
+	queryErr := errors.New("query failed")
+
+	if err := symbolError(queryErr, "BTCUSDT"); !errors.Is(err, queryErr) {
+		t.Fatalf("expected the query error, got %v", err)
+	}
+	joined := errors.Join(service.SymbolErrors{"ETHUSDT": errors.New("trigger failed")}, queryErr)
+	if err := symbolError(joined, "BTCUSDT"); !errors.Is(err, queryErr) {
+		t.Fatalf("expected the query error, got %v", err)
+	}
+}
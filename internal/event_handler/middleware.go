@@ -0,0 +1,107 @@
+package event_handler
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/metrics"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoggingMiddleware logs every event with its outcome and duration
+func LoggingMiddleware(logger *zerolog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event *events.Event) error {
+			eventLogger := logger.With().
+				Str("event_type", event.Type).
+				Str("event_id", event.ID).
+				Time("event_created_at", event.CreatedAt).
+				Logger()
+
+			eventLogger.Debug().Msg("handling event")
+
+			start := time.Now()
+			err := next(ctx, event)
+			if err != nil {
+				eventLogger.Warn().Err(err).Dur("duration", time.Since(start)).Msg("event handler failed")
+				return err
+			}
+
+			eventLogger.Debug().Dur("duration", time.Since(start)).Msg("event handled")
+			return nil
+		}
+	}
+}
+
+// MetricsMiddleware counts events by outcome and observes handler durations
+func MetricsMiddleware(m *metrics.EventHandlerMetrics) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event *events.Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			m.EventsHandled.WithLabelValues(event.Type, status).Inc()
+			m.HandlerDuration.WithLabelValues(event.Type).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// RecoveryMiddleware turns a panicking handler into an error, so that one bad event does
+// not take the worker down
+func RecoveryMiddleware(logger *zerolog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event *events.Event) (err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					logger.Error().
+						Str("event_type", event.Type).
+						Str("event_id", event.ID).
+						Interface("panic", recovered).
+						Bytes("stack", debug.Stack()).
+						Msg("event handler panicked")
+					err = fmt.Errorf("event handler panicked: %v", recovered)
+				}
+			}()
+
+			return next(ctx, event)
+		}
+	}
+}
+
+// TracingMiddleware wraps every event in a span of the global tracer provider
+func TracingMiddleware() Middleware {
+	tracer := otel.Tracer("alerts-worker/event_handler")
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event *events.Event) error {
+			ctx, span := tracer.Start(ctx, "event "+event.Type,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("event.type", event.Type),
+					attribute.String("event.id", event.ID),
+				),
+			)
+			defer span.End()
+
+			err := next(ctx, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package event_handler
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/worker"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrUnknownEventType is returned for events without a registered handler
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// HandlerFunc handles a single event
+type HandlerFunc func(ctx context.Context, event *events.Event) error
+
+// Middleware wraps the handler of every route
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// RouteOptions configures how the handler of an event type runs
+type RouteOptions struct {
+	// Timeout bounds every attempt; zero keeps the caller's deadline
+	Timeout time.Duration
+	// Retry retries failed attempts with backoff; nil makes a single attempt
+	Retry *RetryConfig
+	// Concurrency caps how many events of the type are handled at once; zero is unlimited
+	Concurrency int
+}
+
+// Router dispatches events to the handler registered for their type
+type Router struct {
+	logger     *zerolog.Logger
+	metrics    *metrics.EventHandlerMetrics
+	routes     map[string]HandlerFunc
+	middleware []Middleware
+}
+
+func NewRouter(logger *zerolog.Logger, metrics *metrics.EventHandlerMetrics) *Router {
+	return &Router{
+		logger:  logger,
+		metrics: metrics,
+		routes:  make(map[string]HandlerFunc),
+	}
+}
+
+// Use adds middleware to the routes registered after it. The first middleware is the
+// outermost.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle registers the handler of an event type. Middleware runs once around all
+// attempts, while the concurrency cap and timeout apply to each attempt.
+func (r *Router) Handle(eventType string, handler HandlerFunc, opts RouteOptions) {
+	if _, ok := r.routes[eventType]; ok {
+		panic(fmt.Sprintf("event handler for %q registered twice", eventType))
+	}
+
+	attempt := handler
+	if opts.Timeout > 0 {
+		attempt = withTimeout(attempt, opts.Timeout)
+	}
+	if opts.Concurrency > 0 {
+		attempt = withConcurrency(attempt, opts.Concurrency)
+	}
+
+	route := attempt
+	if opts.Retry != nil {
+		retryConfig := *opts.Retry
+		route = func(ctx context.Context, event *events.Event) error {
+			logger := r.logger.With().Str("event_type", event.Type).Logger()
+			return retry(ctx, &logger, retryConfig, func() error {
+				return attempt(ctx, event)
+			})
+		}
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		route = r.middleware[i](route)
+	}
+
+	r.routes[eventType] = route
+}
+
+// Dispatch runs the handler registered for the event type
+func (r *Router) Dispatch(ctx context.Context, event *events.Event) error {
+	route, ok := r.routes[event.Type]
+	if !ok {
+		if r.metrics != nil {
+			r.metrics.UnknownEvents.WithLabelValues(event.Type).Inc()
+		}
+		// No redelivery can find a handler, so the event goes straight to the dead letters
+		return worker.Permanent(fmt.Errorf("%w: %q", ErrUnknownEventType, event.Type))
+	}
+
+	return route(ctx, event)
+}
+
+func withTimeout(next HandlerFunc, timeout time.Duration) HandlerFunc {
+	return func(ctx context.Context, event *events.Event) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return next(ctx, event)
+	}
+}
+
+func withConcurrency(next HandlerFunc, limit int) HandlerFunc {
+	slots := make(chan struct{}, limit)
+
+	return func(ctx context.Context, event *events.Event) error {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-slots }()
+
+		return next(ctx, event)
+	}
+}
+
+// retry runs fn until it succeeds or the retries are used up, backing off in between
+func retry(ctx context.Context, logger *zerolog.Logger, config RetryConfig, fn func() error) error {
+	var lastErr error
+	backoff := config.InitialBackoff
+
+	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			logger.Debug().
+				Int("attempt", attempt).
+				Dur("backoff", backoff).
+				Err(lastErr).
+				Msg("preparing retry attempt")
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+				// Continue after backoff period
+			}
+
+			backoff = time.Duration(float64(backoff) * config.BackoffFactor)
+			if backoff > config.MaxBackoff {
+				backoff = config.MaxBackoff
+			}
+		}
+
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+		if worker.IsPermanent(lastErr) {
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("failed after %d retries: %w", config.MaxRetries, lastErr)
+}
@@ -1,5 +1,8 @@
 package events
 
 const (
-	EventTypeBinanceMarkPrice = "binance-mark-price-alert"
+	EventTypeBinanceMarkPrice  = "binance-mark-price-alert"
+	EventTypeAlertAcknowledged = "alert-acknowledged"
+	EventTypeTestNotification  = "alert-test-notification"
+	EventTypeAlertTriggered    = "alert-triggered"
 )
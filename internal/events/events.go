@@ -14,6 +14,8 @@ type Event struct {
 	Type      string    `json:"type"`
 	Data      EventData `json:"data"`
 	CreatedAt time.Time `json:"created_at"`
+	// EnqueuedAt is stamped by queue.Queue on publish, for measuring queue latency
+	EnqueuedAt time.Time `json:"enqueued_at,omitzero"`
 }
 
 type BinanceMarkPriceEvent struct {
@@ -25,6 +27,62 @@ type BinanceMarkPriceEvent struct {
 	Timestamp       int64   `json:"timestamp"`
 }
 
+// AlertAcknowledgedEvent cancels pending escalation steps. Without a TriggerID every
+// pending escalation of the alert is cancelled.
+type AlertAcknowledgedEvent struct {
+	AlertID   string `json:"alert_id"`
+	TriggerID string `json:"trigger_id"`
+	UserID    string `json:"user_id"`
+}
+
+// TestNotificationEvent requests a sample notification through every enabled target of
+// AlertID, or every enabled channel of the user when AlertID is empty. Results are
+// written under RequestID.
+type TestNotificationEvent struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+	AlertID   string `json:"alert_id,omitempty"`
+}
+
+// AlertTriggeredSchemaVersion is bumped on any breaking change to AlertTriggeredEvent.
+// Fields may be added without a bump, never renamed or removed.
+const AlertTriggeredSchemaVersion = 1
+
+// AlertTriggeredEvent is published to the outbound stream for other services whenever an
+// alert triggers
+type AlertTriggeredEvent struct {
+	SchemaVersion int                   `json:"schema_version"`
+	TriggerID     string                `json:"trigger_id"`
+	TriggeredAt   time.Time             `json:"triggered_at"`
+	Alert         AlertSnapshot         `json:"alert"`
+	User          UserSnapshot          `json:"user"`
+	MarkPrice     BinanceMarkPriceEvent `json:"mark_price"`
+}
+
+type AlertSnapshot struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	AlertTypeID  string `json:"alert_type_id"`
+	Priority     string `json:"priority"`
+	Conditions   string `json:"conditions"`
+	TriggerCount int    `json:"trigger_count"`
+}
+
+type UserSnapshot struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+// DecodeData converts the generic event data into the typed payload v
+func DecodeData(event *Event, v interface{}) error {
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
 func UnmarshalEvent(data []byte) (*Event, error) {
 	// First unmarshal to get the type
 	var baseEvent Event
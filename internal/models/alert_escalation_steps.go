@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// AlertEscalationStep notifies Channel DelaySeconds after the alert triggered unless the
+// trigger was acknowledged first
+type AlertEscalationStep struct {
+	ID           string              `gorm:"type:varchar(36);primaryKey"`
+	AlertID      string              `gorm:"type:varchar(36);not null;uniqueIndex:idx_alert_escalation_steps_position,priority:1"`
+	Position     int                 `gorm:"not null;uniqueIndex:idx_alert_escalation_steps_position,priority:2"`
+	Channel      NotificationChannel `gorm:"type:varchar(20);not null"`
+	DelaySeconds int                 `gorm:"not null;default:0"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime"`
+	Alert        Alert               `gorm:"foreignKey:AlertID"`
+}
+
+func (AlertEscalationStep) TableName() string {
+	return "alert_escalation_steps"
+}
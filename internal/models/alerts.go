@@ -4,24 +4,43 @@ import (
 	"time"
 )
 
+type AlertPriority string
+
+const (
+	AlertPriorityNormal AlertPriority = "normal"
+	// AlertPriorityUrgent alerts are delivered immediately, bypassing digests
+	AlertPriorityUrgent AlertPriority = "urgent"
+)
+
+// AlertTypeMarkPrice alerts fire when the mark price of Conditions.symbol crosses a level
+const AlertTypeMarkPrice = "mark_price"
+
 type Alert struct {
-	ID            string     `gorm:"type:varchar(36);primaryKey"`
-	UserID        string     `gorm:"type:varchar(36);not null;index"`
-	AlertTypeID   string     `gorm:"type:varchar(50);not null"`
-	Name          string     `gorm:"type:varchar(255);not null"`
-	Description   string     `gorm:"type:text"`
-	Conditions    string     `gorm:"type:text;not null"`
-	IsActive      bool       `gorm:"default:true"`
-	LastTriggered *time.Time `gorm:"null"`
-	TriggerCount  int        `gorm:"default:0"`
-	CreatedAt     time.Time  `gorm:"autoCreateTime"`
-	UpdatedAt     time.Time  `gorm:"autoUpdateTime"`
+	ID          string `gorm:"type:varchar(36);primaryKey"`
+	UserID      string `gorm:"type:varchar(36);not null;index"`
+	AlertTypeID string `gorm:"type:varchar(50);not null;index:idx_alerts_type_symbol,priority:1,where:is_active"`
+	Name        string `gorm:"type:varchar(255);not null"`
+	Description string `gorm:"type:text"`
+	Conditions  string `gorm:"type:text;not null"`
+	// Symbol is generated from Conditions by the database and empty for conditions that
+	// are not a JSON object, so a malformed alert can't break the queries by symbol
+	Symbol        string        `gorm:"type:varchar(50) GENERATED ALWAYS AS (CASE WHEN conditions IS JSON OBJECT THEN conditions::jsonb ->> 'symbol' END) STORED;->;index:idx_alerts_type_symbol,priority:2,where:is_active"`
+	IsActive      bool          `gorm:"default:true"`
+	Priority      AlertPriority `gorm:"type:varchar(20);not null;default:'normal'"`
+	LastTriggered *time.Time    `gorm:"null"`
+	// LastTriggeredTick is the timestamp of the tick that last triggered the alert, to
+	// recognize redelivered ticks
+	LastTriggeredTick *time.Time `gorm:"null"`
+	SnoozedUntil      *time.Time `gorm:"null"`
+	TriggerCount      int        `gorm:"default:0"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime"`
 
-	User               Users                     `gorm:"foreignKey:UserID"`
-	AlertType          AlertType                 `gorm:"foreignKey:AlertTypeID"`
+	User                Users                     `gorm:"foreignKey:UserID"`
+	AlertType           AlertType                 `gorm:"foreignKey:AlertTypeID"`
 	NotificationTargets []AlertNotificationTarget `gorm:"foreignKey:AlertID"`
 }
 
 func (Alert) TableName() string {
 	return "alerts"
-}
\ No newline at end of file
+}
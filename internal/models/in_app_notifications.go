@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// InAppNotification is an entry of the user's notification feed in the app
+type InAppNotification struct {
+	ID        string     `gorm:"type:varchar(36);primaryKey"`
+	UserID    string     `gorm:"type:varchar(36);not null;index:idx_in_app_notifications_user_created,priority:1"`
+	AlertID   *string    `gorm:"type:varchar(36);null"`
+	Title     string     `gorm:"type:varchar(255);not null"`
+	Body      string     `gorm:"type:text;not null"`
+	Symbol    string     `gorm:"type:varchar(50)"`
+	IsRead    bool       `gorm:"default:false"`
+	ReadAt    *time.Time `gorm:"null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index:idx_in_app_notifications_user_created,priority:2"`
+	User      Users      `gorm:"foreignKey:UserID"`
+}
+
+func (InAppNotification) TableName() string {
+	return "in_app_notifications"
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// MutedSymbol suppresses notifications for every alert of the user on Symbol
+type MutedSymbol struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey"`
+	UserID    string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_muted_symbols_user_symbol,priority:1"`
+	Symbol    string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_muted_symbols_user_symbol,priority:2"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	User      Users     `gorm:"foreignKey:UserID"`
+}
+
+func (MutedSymbol) TableName() string {
+	return "muted_symbols"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusSent     DeliveryStatus = "sent"
+	DeliveryStatusRetrying DeliveryStatus = "retrying"
+	DeliveryStatusFailed   DeliveryStatus = "failed"
+	DeliveryStatusDropped  DeliveryStatus = "dropped"
+	DeliveryStatusBuffered DeliveryStatus = "buffered"
+	DeliveryStatusDeferred DeliveryStatus = "deferred"
+)
+
+// NotificationDelivery is the receipt of a single outbox entry, updated after every attempt
+type NotificationDelivery struct {
+	ID                string              `gorm:"type:varchar(36);primaryKey"`
+	OutboxID          string              `gorm:"type:varchar(36);not null;uniqueIndex"`
+	UserID            string              `gorm:"type:varchar(36);not null;index:idx_notification_deliveries_user_created,priority:1"`
+	AlertID           string              `gorm:"type:varchar(36);index"`
+	Channel           NotificationChannel `gorm:"type:varchar(20);not null"`
+	Kind              OutboxKind          `gorm:"type:varchar(20);not null"`
+	Target            string              `gorm:"type:varchar(500);not null"`
+	ProviderMessageID *string             `gorm:"type:varchar(255);null"`
+	Attempts          int                 `gorm:"default:0"`
+	Status            DeliveryStatus      `gorm:"type:varchar(20);not null"`
+	Error             *string             `gorm:"type:text;null"`
+	QueueLatencyMs    int64               `gorm:"default:0"`
+	SendLatencyMs     int64               `gorm:"default:0"`
+	DeliveredAt       *time.Time          `gorm:"null"`
+	CreatedAt         time.Time           `gorm:"autoCreateTime;index:idx_notification_deliveries_user_created,priority:2,sort:desc"`
+	UpdatedAt         time.Time           `gorm:"autoUpdateTime"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationDigestSetting batches a user's notifications into one message per interval.
+// A nil Channel applies to every channel without a channel-specific setting.
+type NotificationDigestSetting struct {
+	ID              string               `gorm:"type:varchar(36);primaryKey"`
+	UserID          string               `gorm:"type:varchar(36);not null;uniqueIndex:idx_notification_digest_settings_user_channel,priority:1"`
+	Channel         *NotificationChannel `gorm:"type:varchar(20);null;uniqueIndex:idx_notification_digest_settings_user_channel,priority:2"`
+	IntervalMinutes int                  `gorm:"not null;default:60"`
+	MaxItems        int                  `gorm:"not null;default:0"`
+	IsEnabled       bool                 `gorm:"default:true"`
+	CreatedAt       time.Time            `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time            `gorm:"autoUpdateTime"`
+	User            Users                `gorm:"foreignKey:UserID"`
+}
+
+func (NotificationDigestSetting) TableName() string {
+	return "notification_digest_settings"
+}
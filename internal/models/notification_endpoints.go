@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+type EndpointPlatform string
+
+const (
+	EndpointPlatformIOS      EndpointPlatform = "ios"
+	EndpointPlatformAndroid  EndpointPlatform = "android"
+	EndpointPlatformTelegram EndpointPlatform = "telegram"
+)
+
+// NotificationEndpoint is one address a user can be reached at on a channel, such as a
+// device token for push or a chat ID for telegram. A user may have many per channel.
+type NotificationEndpoint struct {
+	ID            string              `gorm:"type:varchar(36);primaryKey"`
+	UserID        string              `gorm:"type:varchar(36);not null;index:idx_notification_endpoints_user_channel,priority:1"`
+	Channel       NotificationChannel `gorm:"type:varchar(20);not null;index:idx_notification_endpoints_user_channel,priority:2;uniqueIndex:idx_notification_endpoints_address,priority:1"`
+	Address       string              `gorm:"type:varchar(500);not null;uniqueIndex:idx_notification_endpoints_address,priority:2"`
+	Platform      EndpointPlatform    `gorm:"type:varchar(20);not null"`
+	Label         string              `gorm:"type:varchar(255)"`
+	IsEnabled     bool                `gorm:"default:true"`
+	LastSuccessAt *time.Time          `gorm:"null"`
+	CreatedAt     time.Time           `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time           `gorm:"autoUpdateTime"`
+	User          Users               `gorm:"foreignKey:UserID"`
+}
+
+func (NotificationEndpoint) TableName() string {
+	return "notification_endpoints"
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending    OutboxStatus = "pending"
+	OutboxStatusProcessing OutboxStatus = "processing"
+	OutboxStatusSent       OutboxStatus = "sent"
+	OutboxStatusFailed     OutboxStatus = "failed"
+	OutboxStatusDropped    OutboxStatus = "dropped"
+	OutboxStatusBuffered   OutboxStatus = "buffered"
+	OutboxStatusDeferred   OutboxStatus = "deferred"
+)
+
+type OutboxKind string
+
+const (
+	OutboxKindTrigger OutboxKind = "trigger"
+	OutboxKindSummary OutboxKind = "summary"
+	OutboxKindDigest  OutboxKind = "digest"
+	// OutboxKindAlertTriggered rows carry an alert-triggered event for other services
+	// rather than a notification
+	OutboxKindAlertTriggered OutboxKind = "alert-triggered"
+)
+
+type NotificationOutbox struct {
+	ID          string              `gorm:"type:varchar(36);primaryKey"`
+	AlertID     string              `gorm:"type:varchar(36);not null;index"`
+	UserID      string              `gorm:"type:varchar(36);not null;index"`
+	Channel     NotificationChannel `gorm:"type:varchar(20);not null"`
+	Kind        OutboxKind          `gorm:"type:varchar(20);not null;default:'trigger'"`
+	Recipient   string              `gorm:"type:varchar(500);not null"`
+	Payload     string              `gorm:"type:text;not null"`
+	Status      OutboxStatus        `gorm:"type:varchar(20);not null;default:'pending';index:idx_notification_outbox_claim,priority:1"`
+	Attempts    int                 `gorm:"default:0"`
+	LastError   *string             `gorm:"type:text;null"`
+	AvailableAt time.Time           `gorm:"not null;index:idx_notification_outbox_claim,priority:2"`
+	ClaimedAt   *time.Time          `gorm:"null"`
+	SentAt      *time.Time          `gorm:"null"`
+	CreatedAt   time.Time           `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time           `gorm:"autoUpdateTime"`
+}
+
+func (NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
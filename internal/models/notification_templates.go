@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+const DefaultLocale = "en"
+
+type NotificationTemplate struct {
+	ID          string              `gorm:"type:varchar(36);primaryKey"`
+	AlertTypeID string              `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_templates_key,priority:1"`
+	Channel     NotificationChannel `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_templates_key,priority:2"`
+	Locale      string              `gorm:"type:varchar(10);not null;default:'en';uniqueIndex:idx_notification_templates_key,priority:3"`
+	Subject     string              `gorm:"type:text;not null"`
+	Body        string              `gorm:"type:text;not null"`
+	CreatedAt   time.Time           `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time           `gorm:"autoUpdateTime"`
+}
+
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
@@ -10,8 +10,13 @@ type UserNotificationSettings struct {
 	EmailEnabled    bool      `gorm:"default:true"`
 	TelegramEnabled bool      `gorm:"default:false"`
 	PushEnabled     bool      `gorm:"default:false"`
+	WebPushEnabled  bool      `gorm:"default:false"`
+	InAppEnabled    bool      `gorm:"default:true"`
 	TelegramHandle  *string   `gorm:"type:varchar(255);null"`
+	TelegramChatID  *int64    `gorm:"null;index"`
+	TelegramUserID  *int64    `gorm:"null;index"`
 	DeviceToken     *string   `gorm:"type:varchar(500);null"`
+	Locale          string    `gorm:"type:varchar(10);not null;default:'en'"`
 	CreatedAt       time.Time `gorm:"autoCreateTime"`
 	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
 	User            Users     `gorm:"foreignKey:UserID"`
@@ -23,13 +28,21 @@ const (
 	NotificationChannelEmail    NotificationChannel = "email"
 	NotificationChannelTelegram NotificationChannel = "telegram"
 	NotificationChannelPush     NotificationChannel = "push"
+	NotificationChannelWebPush  NotificationChannel = "web_push"
+	NotificationChannelSlack    NotificationChannel = "slack"
+	NotificationChannelDiscord  NotificationChannel = "discord"
+	NotificationChannelInApp    NotificationChannel = "in_app"
+	// NotificationChannelEvents is the outbox channel of events published to other
+	// services; users can't be notified on it
+	NotificationChannelEvents NotificationChannel = "events"
 )
 
 type AlertNotificationTarget struct {
-	ID        string              `gorm:"type:varchar(36);primaryKey"`
-	AlertID   string              `gorm:"type:varchar(36);not null;index"`
-	Channel   NotificationChannel `gorm:"type:varchar(20);not null"`
-	IsEnabled bool                `gorm:"default:true"`
-	CreatedAt time.Time           `gorm:"autoCreateTime"`
-	Alert     Alert               `gorm:"foreignKey:AlertID"`
-}
\ No newline at end of file
+	ID         string              `gorm:"type:varchar(36);primaryKey"`
+	AlertID    string              `gorm:"type:varchar(36);not null;index"`
+	Channel    NotificationChannel `gorm:"type:varchar(20);not null"`
+	IsEnabled  bool                `gorm:"default:true"`
+	WebhookURL *string             `gorm:"type:varchar(500);null"`
+	CreatedAt  time.Time           `gorm:"autoCreateTime"`
+	Alert      Alert               `gorm:"foreignKey:AlertID"`
+}
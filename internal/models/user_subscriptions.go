@@ -2,6 +2,8 @@ package models
 
 import "time"
 
+var ActiveSubscriptionStatuses = []string{"active", "trialing"}
+
 type UserSubscription struct {
 	ID                     string    `gorm:"type:varchar(36);primaryKey"`
 	UserID                 string    `gorm:"type:varchar(36);not null;index"`
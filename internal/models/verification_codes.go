@@ -2,6 +2,8 @@ package models
 
 import "time"
 
+const VerificationCodeTypeTelegramLink = "telegram_link"
+
 type VerificationCodes struct {
 	ID        int64     `gorm:"primaryKey;autoIncrement;type:bigint"`
 	UserID    string    `gorm:"type:varchar(36);not null"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// WebPushSubscription is a browser push subscription registered by the web dashboard.
+// ExpiredAt is set once the push service reports it gone.
+type WebPushSubscription struct {
+	ID        string     `gorm:"type:varchar(36);primaryKey"`
+	UserID    string     `gorm:"type:varchar(36);not null;index"`
+	Endpoint  string     `gorm:"type:text;not null;uniqueIndex"`
+	P256dh    string     `gorm:"type:varchar(255);not null"`
+	Auth      string     `gorm:"type:varchar(255);not null"`
+	UserAgent string     `gorm:"type:varchar(500)"`
+	ExpiredAt *time.Time `gorm:"null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+	User      Users      `gorm:"foreignKey:UserID"`
+}
+
+func (WebPushSubscription) TableName() string {
+	return "web_push_subscriptions"
+}
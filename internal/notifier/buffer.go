@@ -0,0 +1,203 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// bufferClaimLease is how long a claimed buffer may take to flush before another
+// instance claims it again
+const bufferClaimLease = 2 * time.Minute
+
+// BufferedTrigger is a trigger held back in Redis until its buffer is flushed
+type BufferedTrigger struct {
+	Recipient string  `json:"recipient"`
+	Trigger   Trigger `json:"trigger"`
+}
+
+// TriggerBuffer holds triggers per user and channel in Redis lists and tracks when each
+// list is due for flushing in a sorted set
+type TriggerBuffer struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewTriggerBuffer(client *redis.Client, prefix string) *TriggerBuffer {
+	return &TriggerBuffer{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Add appends item to the user's buffer for channel and returns the buffer size. The
+// buffer becomes due at flushAt unless it was already scheduled earlier.
+func (b *TriggerBuffer) Add(
+	ctx context.Context,
+	userID string,
+	channel models.NotificationChannel,
+	item *BufferedTrigger,
+	flushAt time.Time) (int64, error) {
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal buffered trigger: %w", err)
+	}
+
+	member := bufferMember(userID, channel)
+
+	pipe := b.client.TxPipeline()
+	size := pipe.RPush(ctx, b.listKey(member), data)
+	pipe.ZAddNX(ctx, b.dueKey(), redis.Z{Score: float64(flushAt.UnixMilli()), Member: member})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to buffer trigger: %w", err)
+	}
+
+	return size.Val(), nil
+}
+
+// FlushNow makes the user's buffer for channel due immediately
+func (b *TriggerBuffer) FlushNow(ctx context.Context, userID string, channel models.NotificationChannel) error {
+	return b.client.ZAdd(ctx, b.dueKey(), redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: bufferMember(userID, channel),
+	}).Err()
+}
+
+// Due returns the buffers that should be flushed by now, including those whose claim
+// was abandoned
+func (b *TriggerBuffer) Due(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	due, err := b.client.ZRangeByScore(ctx, b.dueKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	abandoned, err := b.client.ZRangeByScore(ctx, b.claimedKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(now.Add(-bufferClaimLease).UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(due, abandoned...), nil
+}
+
+// BufferClaim is a buffer taken for flushing. Its items stay in Redis until Done is
+// called, so a flush that fails or crashes before creating its outbox rows is retried.
+type BufferClaim struct {
+	UserID  string
+	Channel models.NotificationChannel
+	Items   []BufferedTrigger
+
+	member    string
+	claimedAt int64
+}
+
+// claimScript claims a due buffer, or one whose previous claim is older than the lease,
+// and moves its items to the end of the buffer's processing list, which may still hold
+// the items of an abandoned claim. Buffers claimed by someone else are left alone.
+var claimScript = redis.NewScript(`
+local claimed = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if claimed and tonumber(claimed) >= tonumber(ARGV[3]) then
+	return false
+end
+local due = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not claimed and (not due or tonumber(due) > tonumber(ARGV[2])) then
+	return false
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+local items = redis.call('LRANGE', KEYS[3], 0, -1)
+for i = 1, #items do
+	redis.call('RPUSH', KEYS[4], items[i])
+end
+redis.call('DEL', KEYS[3])
+return redis.call('LRANGE', KEYS[4], 0, -1)
+`)
+
+// doneScript drops the processing list of a claim, unless the claim was taken over
+var doneScript = redis.NewScript(`
+if tonumber(redis.call('ZSCORE', KEYS[1], ARGV[1])) ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('DEL', KEYS[2])
+return 1
+`)
+
+// Claim takes ownership of a due buffer. It returns nil when the buffer is not due or
+// another instance is flushing it.
+func (b *TriggerBuffer) Claim(ctx context.Context, member string) (*BufferClaim, error) {
+	now := time.Now()
+	keys := []string{b.dueKey(), b.claimedKey(), b.listKey(member), b.processingKey(member)}
+
+	raw, err := claimScript.Run(ctx, b.client, keys, member, now.UnixMilli(), now.Add(-bufferClaimLease).UnixMilli()).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim buffer: %w", err)
+	}
+
+	userID, channel, _ := strings.Cut(member, "|")
+	claim := &BufferClaim{
+		UserID:    userID,
+		Channel:   models.NotificationChannel(channel),
+		Items:     make([]BufferedTrigger, 0, len(raw)),
+		member:    member,
+		claimedAt: now.UnixMilli(),
+	}
+
+	for _, data := range raw {
+		var item BufferedTrigger
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			continue
+		}
+		claim.Items = append(claim.Items, item)
+	}
+
+	return claim, nil
+}
+
+// Done removes the items of a claim once they were handed on
+func (b *TriggerBuffer) Done(ctx context.Context, claim *BufferClaim) error {
+	keys := []string{b.claimedKey(), b.processingKey(claim.member)}
+	if err := doneScript.Run(ctx, b.client, keys, claim.member, strconv.FormatInt(claim.claimedAt, 10)).Err(); err != nil {
+		return fmt.Errorf("failed to complete buffer claim: %w", err)
+	}
+	return nil
+}
+
+func (b *TriggerBuffer) listKey(member string) string {
+	return b.prefix + ":" + member
+}
+
+func (b *TriggerBuffer) dueKey() string {
+	return b.prefix + ":due"
+}
+
+func (b *TriggerBuffer) processingKey(member string) string {
+	return b.prefix + ":processing:" + member
+}
+
+// claimedKey is a sorted set of the buffers being flushed, scored by claim time
+func (b *TriggerBuffer) claimedKey() string {
+	return b.prefix + ":claimed"
+}
+
+func bufferMember(userID string, channel models.NotificationChannel) string {
+	return userID + "|" + string(channel)
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Digester holds triggers back for users with digest mode enabled so that they are sent
+// as one summary per interval, or earlier once the configured number of items is reached
+type Digester struct {
+	buffer *TriggerBuffer
+	repo   repository.NotificationDigestSettingsRepository
+	cache  *ttlCache[[]models.NotificationDigestSetting]
+}
+
+func NewDigester(buffer *TriggerBuffer, repo repository.NotificationDigestSettingsRepository, cacheTTL time.Duration) *Digester {
+	return &Digester{
+		buffer: buffer,
+		repo:   repo,
+		cache:  newTTLCache[[]models.NotificationDigestSetting](cacheTTL),
+	}
+}
+
+// Hold buffers the trigger when a digest applies to the user and channel and reports
+// whether it did. Urgent alerts are never held back.
+func (g *Digester) Hold(ctx context.Context, entry *models.NotificationOutbox, trigger *Trigger) (bool, error) {
+	if trigger.Priority == models.AlertPriorityUrgent {
+		return false, nil
+	}
+
+	setting, err := g.settingFor(ctx, entry.UserID, entry.Channel)
+	if err != nil || setting == nil {
+		return false, err
+	}
+
+	item := &BufferedTrigger{Recipient: entry.Recipient, Trigger: *trigger}
+	flushAt := time.Now().Add(time.Duration(setting.IntervalMinutes) * time.Minute)
+
+	size, err := g.buffer.Add(ctx, entry.UserID, entry.Channel, item, flushAt)
+	if err != nil {
+		return false, err
+	}
+
+	if setting.MaxItems > 0 && size >= int64(setting.MaxItems) {
+		if err := g.buffer.FlushNow(ctx, entry.UserID, entry.Channel); err != nil {
+			return true, fmt.Errorf("failed to schedule digest flush: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (g *Digester) settingFor(ctx context.Context, userID string, channel models.NotificationChannel) (*models.NotificationDigestSetting, error) {
+	settings, ok := g.cache.get(userID)
+	if !ok {
+		var err error
+		settings, err = g.repo.GetDigestSettings(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get digest settings: %w", err)
+		}
+		g.cache.set(userID, settings)
+	}
+
+	var fallback *models.NotificationDigestSetting
+	for i := range settings {
+		setting := &settings[i]
+		if setting.IntervalMinutes <= 0 {
+			continue
+		}
+		if setting.Channel == nil {
+			fallback = setting
+		} else if *setting.Channel == channel {
+			return setting, nil
+		}
+	}
+
+	return fallback, nil
+}
@@ -0,0 +1,573 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"alerts-worker/pkg/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+var ErrNoSender = errors.New("no sender configured for channel")
+
+// SummaryPayload is the outbox payload of a message combining several triggers
+type SummaryPayload struct {
+	Triggers []Trigger `json:"triggers"`
+}
+
+type DispatcherOptions struct {
+	WorkerCount  int
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	SendTimeout  time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+}
+
+func DefaultDispatcherOptions() DispatcherOptions {
+	return DispatcherOptions{
+		WorkerCount:  4,
+		BatchSize:    20,
+		PollInterval: 1 * time.Second,
+		Lease:        2 * time.Minute,
+		SendTimeout:  30 * time.Second,
+		MaxAttempts:  5,
+		RetryBackoff: 5 * time.Second,
+		MaxBackoff:   10 * time.Minute,
+	}
+}
+
+// Dispatcher claims pending notification outbox rows and delivers them through the
+// configured senders. Rows are only marked sent after the sender succeeds, so a crash
+// mid-delivery leaves the row claimable again once its lease expires.
+type Dispatcher struct {
+	repo       *repository.Repository
+	senders    Senders
+	renderer   *Renderer
+	limiter    *RateLimiter
+	overflow   *TriggerBuffer
+	digester   *Digester
+	metrics    *metrics.NotificationMetrics
+	logger     *zerolog.Logger
+	opts       DispatcherOptions
+	wg         sync.WaitGroup
+	running    atomic.Bool
+	cancelFunc context.CancelFunc
+}
+
+// NewDispatcher creates a Dispatcher. Without extensions every notification is delivered
+// as soon as it is claimed.
+func NewDispatcher(
+	repo *repository.Repository,
+	senders Senders,
+	renderer *Renderer,
+	metrics *metrics.NotificationMetrics,
+	logger *zerolog.Logger,
+	opts *DispatcherOptions,
+	extensions ...func(*Dispatcher)) *Dispatcher {
+
+	if opts == nil {
+		defaultOpts := DefaultDispatcherOptions()
+		opts = &defaultOpts
+	}
+	if opts.WorkerCount <= 0 {
+		opts.WorkerCount = 1
+	}
+	// Entries are only sent while a whole send timeout is left of their lease
+	if opts.SendTimeout >= opts.Lease {
+		opts.SendTimeout = opts.Lease / 2
+	}
+
+	dispatcher := &Dispatcher{
+		repo:     repo,
+		senders:  senders,
+		renderer: renderer,
+		metrics:  metrics,
+		logger:   logger,
+		opts:     *opts,
+	}
+
+	for _, extend := range extensions {
+		extend(dispatcher)
+	}
+
+	return dispatcher
+}
+
+// WithRateLimiter limits deliveries per user and channel. Overflowing notifications are
+// held back in overflow when the limiter runs in summary mode.
+func WithRateLimiter(limiter *RateLimiter, overflow *TriggerBuffer) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.limiter = limiter
+		d.overflow = overflow
+	}
+}
+
+// WithDigester batches notifications of users in digest mode
+func WithDigester(digester *Digester) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.digester = digester
+	}
+}
+
+func (d *Dispatcher) Start(ctx context.Context) error {
+	if !d.running.CompareAndSwap(false, true) {
+		return errors.New("already running")
+	}
+
+	ctx, d.cancelFunc = context.WithCancel(ctx)
+
+	for i := 0; i < d.opts.WorkerCount; i++ {
+		d.wg.Add(1)
+		go func(workerID int) {
+			defer d.wg.Done()
+			d.run(ctx, workerID)
+		}(i)
+	}
+
+	if d.overflow != nil {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.runFlusher(ctx, d.overflow, models.OutboxKindSummary)
+		}()
+	}
+
+	if d.digester != nil {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.runFlusher(ctx, d.digester.buffer, models.OutboxKindDigest)
+		}()
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) Stop(timeout time.Duration) {
+	if d.cancelFunc != nil {
+		d.cancelFunc()
+	}
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+		d.logger.Warn().Msg("outbox dispatchers forced to stop due to timeout")
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context, workerID int) {
+	logger := d.logger.With().Int("dispatcher_id", workerID).Logger()
+
+	for {
+		entries, err := d.repo.NotificationOutbox.ClaimOutboxEntries(ctx, d.opts.BatchSize, d.opts.Lease)
+		if err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("error claiming outbox entries")
+		}
+
+		for i := range entries {
+			// A send must finish within the lease, otherwise another dispatcher could
+			// claim the entry again and deliver it twice
+			if time.Until(entries[i].ClaimedAt.Add(d.opts.Lease)) < d.opts.SendTimeout {
+				d.release(ctx, &logger, entries[i:])
+				break
+			}
+			d.deliver(ctx, &entries[i])
+		}
+
+		if len(entries) == d.opts.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("outbox dispatcher shutting down")
+			return
+		case <-time.After(d.opts.PollInterval):
+		}
+	}
+}
+
+// release hands back claimed entries the dispatcher ran out of lease for
+func (d *Dispatcher) release(ctx context.Context, logger *zerolog.Logger, entries []models.NotificationOutbox) {
+	ids := make([]string, len(entries))
+	for i := range entries {
+		ids[i] = entries[i].ID
+	}
+
+	if err := d.repo.NotificationOutbox.ReleaseOutboxEntries(context.WithoutCancel(ctx), ids, *entries[0].ClaimedAt); err != nil {
+		logger.Error().Err(err).Msg("error releasing outbox entries")
+		return
+	}
+	logger.Warn().Int("entries", len(entries)).Msg("outbox lease ran out, released undelivered entries")
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, entry *models.NotificationOutbox) {
+	logger := d.logger.With().
+		Str("outbox_id", entry.ID).
+		Str("channel", string(entry.Channel)).
+		Str("kind", string(entry.Kind)).
+		Int("attempt", entry.Attempts).
+		Logger()
+
+	// Bookkeeping must survive shutdown, otherwise a delivered row would be sent again
+	storeCtx := context.WithoutCancel(ctx)
+
+	if entry.Kind == models.OutboxKindTrigger && d.digester != nil {
+		if d.holdForDigest(storeCtx, &logger, entry) {
+			return
+		}
+	}
+
+	// An entry takes a token once; retries were already let through
+	if entry.Kind == models.OutboxKindTrigger && d.limiter != nil && firstAttempt(entry) {
+		allowed, retryAfter, err := d.limiter.Allow(ctx, entry.UserID, entry.Channel)
+		if err != nil {
+			logger.Warn().Err(err).Msg("rate limiter unavailable, delivering without limit")
+		} else if !allowed {
+			d.handleOverflow(storeCtx, &logger, entry, retryAfter)
+			return
+		}
+	}
+
+	sendStart := time.Now()
+	providerID, sendErr := d.send(ctx, entry)
+	sendDuration := time.Since(sendStart)
+	d.metrics.NotificationSendDuration.WithLabelValues(string(entry.Channel)).Observe(sendDuration.Seconds())
+
+	if sendErr == nil {
+		if err := d.repo.NotificationOutbox.MarkOutboxSent(storeCtx, entry.ID, *entry.ClaimedAt); err != nil {
+			logger.Error().Err(err).Msg("error marking outbox entry as sent")
+		}
+		d.recordDelivery(storeCtx, &logger, entry, models.DeliveryStatusSent, providerID, "", sendStart, sendDuration)
+		logger.Debug().Str("provider_message_id", providerID).Msg("notification delivered")
+		return
+	}
+
+	if retryAt, ok := deferUntil(sendErr); ok {
+		if err := d.repo.NotificationOutbox.DeferOutboxEntry(storeCtx, entry.ID, *entry.ClaimedAt, sendErr.Error(), retryAt); err != nil {
+			logger.Error().Err(err).Msg("error deferring outbox entry")
+		}
+		d.recordDelivery(storeCtx, &logger, entry, models.DeliveryStatusDeferred, "", sendErr.Error(), sendStart, sendDuration)
+		logger.Warn().Err(sendErr).Time("retry_at", retryAt).Msg("channel unavailable, notification deferred")
+		return
+	}
+
+	if errors.Is(sendErr, ErrNoSender) || isPermanent(sendErr) || entry.Attempts >= d.opts.MaxAttempts {
+		if err := d.repo.NotificationOutbox.MarkOutboxFailed(storeCtx, entry.ID, *entry.ClaimedAt, sendErr.Error()); err != nil {
+			logger.Error().Err(err).Msg("error marking outbox entry as failed")
+		}
+		d.recordDelivery(storeCtx, &logger, entry, models.DeliveryStatusFailed, "", sendErr.Error(), sendStart, sendDuration)
+		logger.Error().Err(sendErr).Msg("notification delivery failed permanently")
+		return
+	}
+
+	retryAt := time.Now().Add(d.backoff(entry.Attempts))
+	if err := d.repo.NotificationOutbox.RescheduleOutboxEntry(storeCtx, entry.ID, *entry.ClaimedAt, sendErr.Error(), retryAt); err != nil {
+		logger.Error().Err(err).Msg("error rescheduling outbox entry")
+	}
+	d.recordDelivery(storeCtx, &logger, entry, models.DeliveryStatusRetrying, "", sendErr.Error(), sendStart, sendDuration)
+	logger.Warn().Err(sendErr).Time("retry_at", retryAt).Msg("notification delivery failed, rescheduled")
+}
+
+// firstAttempt reports whether entry is claimed for the first time. Deferred and
+// rescheduled entries keep their last error, and abandoned ones count an extra attempt.
+func firstAttempt(entry *models.NotificationOutbox) bool {
+	return entry.Attempts == 1 && entry.LastError == nil
+}
+
+// recordDelivery saves the delivery receipt of entry after an attempt and counts its outcome
+func (d *Dispatcher) recordDelivery(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	entry *models.NotificationOutbox,
+	status models.DeliveryStatus,
+	providerID string,
+	reason string,
+	sendStart time.Time,
+	sendDuration time.Duration) {
+
+	d.metrics.NotificationDeliveries.WithLabelValues(string(entry.Channel), string(status)).Inc()
+
+	delivery := &models.NotificationDelivery{
+		ID:             uuid.NewString(),
+		OutboxID:       entry.ID,
+		UserID:         entry.UserID,
+		AlertID:        entry.AlertID,
+		Channel:        entry.Channel,
+		Kind:           entry.Kind,
+		Target:         entry.Recipient,
+		Attempts:       entry.Attempts,
+		Status:         status,
+		QueueLatencyMs: sendStart.Sub(entry.CreatedAt).Milliseconds(),
+		SendLatencyMs:  sendDuration.Milliseconds(),
+	}
+	if providerID != "" {
+		delivery.ProviderMessageID = &providerID
+	}
+	if reason != "" {
+		delivery.Error = &reason
+	}
+	if status == models.DeliveryStatusSent {
+		deliveredAt := sendStart.Add(sendDuration)
+		delivery.DeliveredAt = &deliveredAt
+		d.metrics.NotificationDeliveryLatency.WithLabelValues(string(entry.Channel)).Observe(deliveredAt.Sub(entry.CreatedAt).Seconds())
+	}
+
+	if err := d.repo.NotificationDeliveries.SaveDelivery(ctx, delivery); err != nil {
+		logger.Error().Err(err).Msg("error saving delivery receipt")
+	}
+}
+
+// holdForDigest moves the notification into the user's digest when one applies
+func (d *Dispatcher) holdForDigest(ctx context.Context, logger *zerolog.Logger, entry *models.NotificationOutbox) bool {
+	var trigger Trigger
+	if err := json.Unmarshal([]byte(entry.Payload), &trigger); err != nil {
+		return false
+	}
+
+	held, err := d.digester.Hold(ctx, entry, &trigger)
+	if err != nil {
+		logger.Warn().Err(err).Msg("error applying digest")
+	}
+	if !held {
+		return false
+	}
+
+	if err := d.repo.NotificationOutbox.SetOutboxStatus(ctx, entry.ID, *entry.ClaimedAt, models.OutboxStatusBuffered, "digest"); err != nil {
+		logger.Error().Err(err).Msg("error marking outbox entry as buffered")
+	}
+	d.recordDelivery(ctx, logger, entry, models.DeliveryStatusBuffered, "", "digest", time.Now(), 0)
+	logger.Debug().Msg("notification held back for digest")
+
+	return true
+}
+
+// handleOverflow drops a rate limited notification or holds it back for the next summary
+func (d *Dispatcher) handleOverflow(ctx context.Context, logger *zerolog.Logger, entry *models.NotificationOutbox, retryAfter time.Duration) {
+	mode := d.limiter.Overflow()
+	d.metrics.NotificationsRateLimited.WithLabelValues(string(entry.Channel), string(mode)).Inc()
+
+	if mode == OverflowSummary && d.overflow != nil {
+		var trigger Trigger
+		if err := json.Unmarshal([]byte(entry.Payload), &trigger); err != nil {
+			logger.Error().Err(err).Msg("error unmarshaling rate limited outbox payload")
+		} else {
+			item := &BufferedTrigger{Recipient: entry.Recipient, Trigger: trigger}
+			_, err := d.overflow.Add(ctx, entry.UserID, entry.Channel, item, time.Now().Add(retryAfter))
+			if err == nil {
+				if err := d.repo.NotificationOutbox.SetOutboxStatus(ctx, entry.ID, *entry.ClaimedAt, models.OutboxStatusBuffered, "rate limited"); err != nil {
+					logger.Error().Err(err).Msg("error marking outbox entry as buffered")
+				}
+				d.recordDelivery(ctx, logger, entry, models.DeliveryStatusBuffered, "", "rate limited", time.Now(), 0)
+				logger.Debug().Dur("retry_after", retryAfter).Msg("notification rate limited, held back for summary")
+				return
+			}
+			logger.Error().Err(err).Msg("error buffering rate limited notification")
+		}
+	}
+
+	if err := d.repo.NotificationOutbox.SetOutboxStatus(ctx, entry.ID, *entry.ClaimedAt, models.OutboxStatusDropped, "rate limited"); err != nil {
+		logger.Error().Err(err).Msg("error marking outbox entry as dropped")
+	}
+	d.recordDelivery(ctx, logger, entry, models.DeliveryStatusDropped, "", "rate limited", time.Now(), 0)
+	logger.Debug().Msg("notification rate limited, dropped")
+}
+
+func (d *Dispatcher) send(ctx context.Context, entry *models.NotificationOutbox) (string, error) {
+	sender, ok := d.senders[entry.Channel]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoSender, entry.Channel)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.opts.SendTimeout)
+	defer cancel()
+
+	msg := &Message{
+		OutboxID:  entry.ID,
+		UserID:    entry.UserID,
+		AlertID:   entry.AlertID,
+		Channel:   entry.Channel,
+		Recipient: entry.Recipient,
+		HTML:      entry.Channel == models.NotificationChannelEmail,
+	}
+
+	if err := d.render(sendCtx, entry, msg); err != nil {
+		return "", err
+	}
+
+	return sender.Send(sendCtx, msg)
+}
+
+// render fills in the subject and body of msg from the outbox payload
+func (d *Dispatcher) render(ctx context.Context, entry *models.NotificationOutbox, msg *Message) error {
+	var err error
+
+	// Events are published as they were queued
+	if entry.Kind == models.OutboxKindAlertTriggered {
+		msg.Body = entry.Payload
+		return nil
+	}
+
+	if entry.Kind == models.OutboxKindTrigger {
+		var trigger Trigger
+		if err := json.Unmarshal([]byte(entry.Payload), &trigger); err != nil {
+			return fmt.Errorf("failed to unmarshal outbox payload: %w", err)
+		}
+		msg.Symbol = trigger.Symbol
+		msg.Subject, msg.Body, err = d.renderer.Render(ctx, entry.Channel, &trigger)
+		return err
+	}
+
+	var summary SummaryPayload
+	if err := json.Unmarshal([]byte(entry.Payload), &summary); err != nil {
+		return fmt.Errorf("failed to unmarshal outbox payload: %w", err)
+	}
+	msg.Subject, msg.Body, err = d.renderer.RenderSummary(entry.Kind, entry.Channel, summary.Triggers)
+	return err
+}
+
+// runFlusher periodically turns due trigger buffers into outbox rows of the given kind
+func (d *Dispatcher) runFlusher(ctx context.Context, buffer *TriggerBuffer, kind models.OutboxKind) {
+	logger := d.logger.With().Str("kind", string(kind)).Logger()
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("buffer flusher shutting down")
+			return
+		case <-ticker.C:
+			if err := d.flush(ctx, buffer, kind); err != nil && ctx.Err() == nil {
+				logger.Error().Err(err).Msg("error flushing trigger buffers")
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) flush(ctx context.Context, buffer *TriggerBuffer, kind models.OutboxKind) error {
+	members, err := buffer.Due(ctx, time.Now(), int64(d.opts.BatchSize))
+	if err != nil {
+		return err
+	}
+
+	storeCtx := context.WithoutCancel(ctx)
+
+	for _, member := range members {
+		claim, err := buffer.Claim(ctx, member)
+		if err != nil {
+			return err
+		}
+		if claim == nil {
+			continue
+		}
+
+		entries, err := summaryEntries(claim.UserID, claim.Channel, kind, claim.Items)
+		if err != nil {
+			return err
+		}
+
+		// The buffered items are only removed once their outbox rows exist
+		if err := d.repo.NotificationOutbox.CreateOutboxEntries(storeCtx, entries); err != nil {
+			return fmt.Errorf("failed to create %s outbox entries: %w", kind, err)
+		}
+		if err := buffer.Done(storeCtx, claim); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// summaryEntries groups buffered triggers by recipient into one outbox row each
+func summaryEntries(userID string, channel models.NotificationChannel, kind models.OutboxKind, items []BufferedTrigger) ([]models.NotificationOutbox, error) {
+	byRecipient := make(map[string][]Trigger)
+	var recipients []string
+	for _, item := range items {
+		if _, ok := byRecipient[item.Recipient]; !ok {
+			recipients = append(recipients, item.Recipient)
+		}
+		byRecipient[item.Recipient] = append(byRecipient[item.Recipient], item.Trigger)
+	}
+
+	now := time.Now()
+	entries := make([]models.NotificationOutbox, 0, len(recipients))
+	for _, recipient := range recipients {
+		payload, err := json.Marshal(SummaryPayload{Triggers: byRecipient[recipient]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s payload: %w", kind, err)
+		}
+
+		entries = append(entries, models.NotificationOutbox{
+			ID:          uuid.NewString(),
+			UserID:      userID,
+			Channel:     channel,
+			Kind:        kind,
+			Recipient:   recipient,
+			Payload:     string(payload),
+			Status:      models.OutboxStatusPending,
+			AvailableAt: now,
+		})
+	}
+
+	return entries, nil
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.opts.RetryBackoff
+	for i := 1; i < attempt && backoff < d.opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.opts.MaxBackoff {
+		backoff = d.opts.MaxBackoff
+	}
+	return backoff
+}
+
+// isPermanent reports whether retrying cannot succeed because the user has nowhere left
+// to receive the message. A joined error is only permanent when all of its errors are.
+func isPermanent(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !isPermanent(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return errors.Is(err, ErrNoRecipients) || errors.Is(err, ErrEndpointGone)
+}
+
+// deferUntil returns when a send refused because of open breakers or provider throttling
+// may be retried. Deferring does not use up an attempt.
+func deferUntil(err error) (time.Time, bool) {
+	var circuitErr *CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return circuitErr.RetryAt, true
+	}
+
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) {
+		return throttledErr.RetryAt, true
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// EmailSender delivers messages through an SMTP relay
+type EmailSender struct {
+	cfg EmailConfig
+}
+
+func NewEmailSender(cfg EmailConfig) *EmailSender {
+	return &EmailSender{cfg: cfg}
+}
+
+func (s *EmailSender) Send(ctx context.Context, msg *Message) (string, error) {
+	messageID := s.messageID()
+
+	if err := s.sendMail(ctx, msg.Recipient, s.buildMessage(msg, messageID)); err != nil {
+		// A connection cut short by ctx fails with an i/o timeout; report the cause instead
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return messageID, nil
+}
+
+// sendMail does what smtp.SendMail does, on a connection bound to ctx: it takes the ctx
+// deadline and is cut off once ctx is cancelled, so a stalled relay can't outlive the
+// delivery lease
+func (s *EmailSender) sendMail(ctx context.Context, recipient string, message []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(recipient); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// messageID generates the Message-ID header, which SMTP relays keep as the message identifier
+func (s *EmailSender) messageID() string {
+	domain := s.cfg.Host
+	if _, after, ok := strings.Cut(s.cfg.From, "@"); ok {
+		domain = strings.TrimSuffix(after, ">")
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
+}
+
+func (s *EmailSender) buildMessage(msg *Message, messageID string) []byte {
+	var b strings.Builder
+	b.WriteString("Message-ID: " + messageID + "\r\n")
+	b.WriteString("From: " + s.cfg.From + "\r\n")
+	b.WriteString("To: " + headerValue(msg.Recipient) + "\r\n")
+	b.WriteString("Subject: " + headerValue(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML {
+		b.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	} else {
+		b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return []byte(b.String())
+}
+
+// headerValue replaces line breaks, which would let a value inject further headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
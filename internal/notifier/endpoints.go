@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// EndpointSender fans a message out to every active endpoint the user registered for the
+// channel. The outbox recipient is kept as a fallback address for users who only have
+// the single address from their notification settings.
+type EndpointSender struct {
+	channel   models.NotificationChannel
+	sender    Sender
+	endpoints repository.NotificationEndpointRepository
+	logger    *zerolog.Logger
+}
+
+func NewEndpointSender(
+	channel models.NotificationChannel,
+	sender Sender,
+	endpoints repository.NotificationEndpointRepository,
+	logger *zerolog.Logger) *EndpointSender {
+
+	return &EndpointSender{
+		channel:   channel,
+		sender:    sender,
+		endpoints: endpoints,
+		logger:    logger,
+	}
+}
+
+// Send succeeds when at least one endpoint accepted the message. Endpoints the provider
+// reports as gone are disabled.
+func (s *EndpointSender) Send(ctx context.Context, msg *Message) (string, error) {
+	endpoints, err := s.endpoints.GetActiveEndpoints(ctx, msg.UserID, s.channel)
+	if err != nil {
+		return "", err
+	}
+
+	// The fallback address is only used while it has no endpoint row at all; a disabled
+	// row means the address was turned off or reported gone
+	if msg.Recipient != "" {
+		_, err := s.endpoints.GetEndpointByAddress(ctx, s.channel, msg.Recipient)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			endpoints = append(endpoints, models.NotificationEndpoint{Address: msg.Recipient})
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	if len(endpoints) == 0 {
+		return "", ErrNoRecipients
+	}
+
+	var messageID string
+	var delivered bool
+	var errs []error
+
+	for _, endpoint := range endpoints {
+		endpointMsg := *msg
+		endpointMsg.Recipient = endpoint.Address
+
+		providerID, err := s.sender.Send(ctx, &endpointMsg)
+		if errors.Is(err, ErrEndpointGone) {
+			// The settings address has no endpoint row to disable
+			if endpoint.ID != "" {
+				if err := s.endpoints.DisableEndpoint(ctx, endpoint.ID); err != nil {
+					s.logger.Error().Err(err).Str("endpoint_id", endpoint.ID).Msg("error disabling notification endpoint")
+				}
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !delivered {
+			messageID = providerID
+			delivered = true
+		}
+
+		if endpoint.ID != "" {
+			if err := s.endpoints.MarkEndpointSucceeded(ctx, endpoint.ID, time.Now()); err != nil {
+				s.logger.Error().Err(err).Str("endpoint_id", endpoint.ID).Msg("error updating notification endpoint")
+			}
+		}
+	}
+
+	if delivered {
+		return messageID, nil
+	}
+
+	return "", errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/pkg/circuitbreaker"
+	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/telegram"
+	"alerts-worker/pkg/webpush"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("all providers for channel are unavailable")
+
+// CircuitOpenError is returned when every provider of a channel has an open breaker
+type CircuitOpenError struct {
+	Channel models.NotificationChannel
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s, retry at %s", ErrCircuitOpen, e.Channel, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// Provider is a named Sender for a channel, such as a single SMTP relay
+type Provider struct {
+	Name   string
+	Sender Sender
+}
+
+type guardedProvider struct {
+	Provider
+	breaker *circuitbreaker.Breaker
+}
+
+// FailoverSender tries the providers of a channel in order, skipping providers whose
+// circuit breaker is open
+type FailoverSender struct {
+	channel   models.NotificationChannel
+	providers []guardedProvider
+	metrics   *metrics.NotificationMetrics
+}
+
+func NewFailoverSender(
+	channel models.NotificationChannel,
+	providers []Provider,
+	opts circuitbreaker.Options,
+	metrics *metrics.NotificationMetrics) *FailoverSender {
+
+	sender := &FailoverSender{
+		channel: channel,
+		metrics: metrics,
+	}
+
+	for _, p := range providers {
+		stateGauge := metrics.ProviderCircuitState.WithLabelValues(string(channel), p.Name)
+		stateGauge.Set(float64(circuitbreaker.StateClosed))
+
+		sender.providers = append(sender.providers, guardedProvider{
+			Provider: p,
+			breaker: circuitbreaker.New(opts, func(state circuitbreaker.State) {
+				stateGauge.Set(float64(state))
+			}),
+		})
+	}
+
+	return sender
+}
+
+func (s *FailoverSender) Send(ctx context.Context, msg *Message) (string, error) {
+	var lastErr error
+	retryAt := time.Time{}
+
+	for i, p := range s.providers {
+		if err := p.breaker.Allow(); err != nil {
+			if at := p.breaker.RetryAt(); retryAt.IsZero() || at.Before(retryAt) {
+				retryAt = at
+			}
+			continue
+		}
+
+		if i > 0 {
+			s.metrics.ProviderFailovers.WithLabelValues(string(s.channel), p.Name).Inc()
+		}
+
+		providerID, err := p.Sender.Send(ctx, msg)
+		if err == nil {
+			p.breaker.Success()
+			return providerID, nil
+		}
+
+		// The provider is healthy, the address or message isn't; another provider won't
+		// do better
+		if !isProviderFailure(err) {
+			p.breaker.Success()
+			return "", err
+		}
+
+		// Throttling is the provider working as intended, not an outage
+		var throttledErr *ThrottledError
+		if errors.As(err, &throttledErr) {
+			p.breaker.Cancel()
+			return "", err
+		}
+
+		// A canceled send says nothing about the provider's health
+		if ctx.Err() != nil {
+			p.breaker.Cancel()
+			return "", err
+		}
+
+		p.breaker.Failure()
+		lastErr = fmt.Errorf("%s: %w", p.Name, err)
+	}
+
+	if lastErr != nil {
+		return "", lastErr
+	}
+
+	return "", &CircuitOpenError{Channel: s.channel, RetryAt: retryAt}
+}
+
+// isProviderFailure reports whether err says the provider is unhealthy, rather than that
+// it refused the recipient or the message. Only transport errors, 5xx and 429 answers
+// count as failures. A joined error is a failure when any of its errors is.
+func isProviderFailure(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if isProviderFailure(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if errors.Is(err, ErrNoRecipients) || errors.Is(err, ErrEndpointGone) {
+		return false
+	}
+
+	if status, ok := statusCode(err); ok {
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+// statusCode returns the HTTP status of a provider's answer carried by err
+func statusCode(err error) (int, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+
+	var telegramErr *telegram.Error
+	if errors.As(err, &telegramErr) {
+		return telegramErr.StatusCode, true
+	}
+
+	var webPushErr *webpush.StatusError
+	if errors.As(err, &webPushErr) {
+		return webPushErr.StatusCode, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// InAppSender stores messages in the in-app feed and publishes them to the user's pub/sub
+// channel for the websocket gateway to relay
+type InAppSender struct {
+	notifications repository.InAppNotificationRepository
+	client        *redis.Client
+	channelPrefix string
+}
+
+func NewInAppSender(notifications repository.InAppNotificationRepository, client *redis.Client, channelPrefix string) *InAppSender {
+	return &InAppSender{
+		notifications: notifications,
+		client:        client,
+		channelPrefix: channelPrefix,
+	}
+}
+
+// inAppEvent is the message published to the gateway
+type inAppEvent struct {
+	ID        string    `json:"id"`
+	AlertID   string    `json:"alert_id,omitempty"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Symbol    string    `json:"symbol,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *InAppSender) Send(ctx context.Context, msg *Message) (string, error) {
+	// The outbox ID doubles as the feed entry ID to keep retries idempotent
+	id := msg.OutboxID
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	notification := &models.InAppNotification{
+		ID:        id,
+		UserID:    msg.UserID,
+		Title:     msg.Subject,
+		Body:      msg.Body,
+		Symbol:    msg.Symbol,
+		CreatedAt: time.Now(),
+	}
+	if msg.AlertID != "" {
+		notification.AlertID = &msg.AlertID
+	}
+
+	if err := s.notifications.CreateInAppNotification(ctx, notification); err != nil {
+		return "", fmt.Errorf("failed to create in-app notification: %w", err)
+	}
+
+	payload, err := json.Marshal(&inAppEvent{
+		ID:        notification.ID,
+		AlertID:   msg.AlertID,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Symbol:    notification.Symbol,
+		CreatedAt: notification.CreatedAt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal in-app notification: %w", err)
+	}
+
+	if err := s.client.Publish(ctx, s.channelPrefix+":"+msg.UserID, payload).Err(); err != nil {
+		return "", fmt.Errorf("failed to publish in-app notification: %w", err)
+	}
+
+	return notification.ID, nil
+}
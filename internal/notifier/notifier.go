@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Trigger is the snapshot of a fired alert stored as the outbox payload
+type Trigger struct {
+	TriggerID       string               `json:"trigger_id"`
+	AlertID         string               `json:"alert_id"`
+	AlertName       string               `json:"alert_name"`
+	AlertTypeID     string               `json:"alert_type_id"`
+	UserID          string               `json:"user_id"`
+	UserDisplayName string               `json:"user_display_name"`
+	Locale          string               `json:"locale"`
+	Symbol          string               `json:"symbol"`
+	Price           float64              `json:"price"`
+	Condition       string               `json:"condition"`
+	Priority        models.AlertPriority `json:"priority"`
+	TriggeredAt     time.Time            `json:"triggered_at"`
+}
+
+// Message is a rendered notification ready to be handed to a Sender. AlertID and
+// Symbol are only set for single trigger notifications.
+type Message struct {
+	OutboxID  string
+	UserID    string
+	AlertID   string
+	Symbol    string
+	Channel   models.NotificationChannel
+	Recipient string
+	Subject   string
+	Body      string
+	HTML      bool
+}
+
+var (
+	// ErrNoRecipients is returned when the user has no address left on the channel
+	ErrNoRecipients = errors.New("no active recipients for channel")
+	// ErrEndpointGone is returned by a Sender when the provider reports the address
+	// will never accept messages again, such as an unregistered device token
+	ErrEndpointGone = errors.New("notification endpoint is gone")
+)
+
+// StatusError is returned by a Sender when the provider answers with an unsuccessful
+// HTTP status
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Sender delivers a message over a single notification channel and returns the
+// provider's message ID when it reports one
+type Sender interface {
+	Send(ctx context.Context, msg *Message) (string, error)
+}
+
+// Senders maps every configured channel to its Sender
+type Senders map[models.NotificationChannel]Sender
@@ -0,0 +1,203 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const firebaseMessagingScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// PushSender delivers messages to mobile devices through Firebase Cloud Messaging (HTTP v1)
+type PushSender struct {
+	projectID string
+	baseURL   string
+	client    *http.Client
+	account   *serviceAccount
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+func NewPushSender(projectID string, credentialsFile string, baseURL string) (*PushSender, error) {
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firebase credentials: %w", err)
+	}
+
+	account := new(serviceAccount)
+	if err := json.Unmarshal(raw, account); err != nil {
+		return nil, fmt.Errorf("failed to parse firebase credentials: %w", err)
+	}
+
+	return &PushSender{
+		projectID: projectID,
+		baseURL:   baseURL,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		account:   account,
+	}, nil
+}
+
+func (s *PushSender) Send(ctx context.Context, msg *Message) (string, error) {
+	token, err := s.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": msg.Recipient,
+			"notification": map[string]string{
+				"title": msg.Subject,
+				"body":  msg.Body,
+			},
+			"data": map[string]string{
+				"alert_id": msg.AlertID,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal push request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", s.baseURL, s.projectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// FCM answers 404 UNREGISTERED for tokens of uninstalled apps
+		if resp.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("%w: %s", ErrEndpointGone, respBody)
+		}
+		return "", &StatusError{Provider: "push", StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var result struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode push response: %w", err)
+	}
+
+	return result.Name, nil
+}
+
+// token returns a cached OAuth2 access token, exchanging a signed service account
+// assertion for a new one shortly before the current token expires
+func (s *PushSender) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.expiresAt.Add(-time.Minute)) {
+		return s.accessToken, nil
+	}
+
+	assertion, err := s.signAssertion()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	s.accessToken = result.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+
+	return s.accessToken, nil
+}
+
+func (s *PushSender) signAssertion() (string, error) {
+	block, _ := pem.Decode([]byte(s.account.PrivateKey))
+	if block == nil {
+		return "", errors.New("invalid firebase private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse firebase private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("firebase private key is not an RSA key")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   s.account.ClientEmail,
+		"scope": firebaseMessagingScope,
+		"aud":   s.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"alerts-worker/pkg/ratelimit"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OverflowMode string
+
+const (
+	// OverflowDrop discards rate limited notifications
+	OverflowDrop OverflowMode = "drop"
+	// OverflowSummary holds rate limited notifications back and sends them as one summary message
+	OverflowSummary OverflowMode = "summary"
+)
+
+type RateLimitOptions struct {
+	Default      ratelimit.Limit
+	Overflow     OverflowMode
+	PlanCacheTTL time.Duration
+}
+
+type planRateLimit struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// planLimits is the notification part of SubscriptionPlan.Limits, keyed by channel or "default"
+type planLimits struct {
+	NotificationRateLimits map[string]planRateLimit `json:"notification_rate_limits"`
+}
+
+// RateLimiter applies a per-user, per-channel token bucket whose size comes from the
+// user's subscription plan, falling back to the configured default
+type RateLimiter struct {
+	bucket *ratelimit.TokenBucket
+	repo   repository.SubscriptionRepository
+	opts   RateLimitOptions
+	cache  *ttlCache[planLimits]
+}
+
+func NewRateLimiter(bucket *ratelimit.TokenBucket, repo repository.SubscriptionRepository, opts RateLimitOptions) *RateLimiter {
+	if opts.Overflow == "" {
+		opts.Overflow = OverflowDrop
+	}
+
+	return &RateLimiter{
+		bucket: bucket,
+		repo:   repo,
+		opts:   opts,
+		cache:  newTTLCache[planLimits](opts.PlanCacheTTL),
+	}
+}
+
+func (l *RateLimiter) Overflow() OverflowMode {
+	return l.opts.Overflow
+}
+
+// Allow takes a token from the user's bucket for channel
+func (l *RateLimiter) Allow(ctx context.Context, userID string, channel models.NotificationChannel) (bool, time.Duration, error) {
+	limit, err := l.limitFor(ctx, userID, channel)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return l.bucket.Allow(ctx, userID+":"+string(channel), limit)
+}
+
+func (l *RateLimiter) limitFor(ctx context.Context, userID string, channel models.NotificationChannel) (ratelimit.Limit, error) {
+	limits, err := l.planLimits(ctx, userID)
+	if err != nil {
+		return ratelimit.Limit{}, err
+	}
+
+	for _, key := range []string{string(channel), "default"} {
+		if limit, ok := limits.NotificationRateLimits[key]; ok {
+			return ratelimit.PerMinute(limit.PerMinute, limit.Burst), nil
+		}
+	}
+
+	return l.opts.Default, nil
+}
+
+func (l *RateLimiter) planLimits(ctx context.Context, userID string) (planLimits, error) {
+	if limits, ok := l.cache.get(userID); ok {
+		return limits, nil
+	}
+
+	var limits planLimits
+	plan, err := l.repo.GetActiveSubscriptionPlan(ctx, userID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return planLimits{}, fmt.Errorf("failed to get subscription plan: %w", err)
+	case plan.Limits != "":
+		if err := json.Unmarshal([]byte(plan.Limits), &limits); err != nil {
+			return planLimits{}, fmt.Errorf("failed to parse limits of plan %s: %w", plan.ID, err)
+		}
+	}
+
+	l.cache.set(userID, limits)
+
+	return limits, nil
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/queue"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// StreamSender publishes the events queued in the outbox to a Redis stream. The message
+// body is the JSON encoded event.
+type StreamSender struct {
+	stream *queue.Stream
+}
+
+func NewStreamSender(stream *queue.Stream) *StreamSender {
+	return &StreamSender{stream: stream}
+}
+
+func (s *StreamSender) Send(ctx context.Context, msg *Message) (string, error) {
+	var event events.Event
+	if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+		return "", fmt.Errorf("failed to unmarshal outbox event: %w", err)
+	}
+
+	id, err := s.stream.Publish(ctx, &event)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish outbox event: %w", err)
+	}
+
+	return id, nil
+}
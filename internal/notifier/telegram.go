@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"alerts-worker/pkg/telegram"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertAction is an action a user can apply to an alert from an inline button
+type AlertAction string
+
+const (
+	AlertActionSnooze  AlertAction = "s"
+	AlertActionDisable AlertAction = "d"
+	AlertActionMute    AlertAction = "m"
+)
+
+// maxCallbackDataLength is the Bot API limit for callback_data
+const maxCallbackDataLength = 64
+
+var ErrInvalidCallbackData = errors.New("invalid callback data")
+
+// EncodeAlertAction packs an action into callback_data as "<action>:<alertID>[:<symbol>]"
+func EncodeAlertAction(action AlertAction, alertID string, symbol string) string {
+	data := string(action) + ":" + alertID
+	if symbol != "" {
+		data += ":" + symbol
+	}
+	return data
+}
+
+// ParseAlertAction is the inverse of EncodeAlertAction
+func ParseAlertAction(data string) (AlertAction, string, string, error) {
+	parts := strings.SplitN(data, ":", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return "", "", "", ErrInvalidCallbackData
+	}
+
+	action := AlertAction(parts[0])
+	switch action {
+	case AlertActionSnooze, AlertActionDisable:
+		return action, parts[1], "", nil
+	case AlertActionMute:
+		if len(parts) < 3 || parts[2] == "" {
+			return "", "", "", ErrInvalidCallbackData
+		}
+		return action, parts[1], parts[2], nil
+	}
+
+	return "", "", "", ErrInvalidCallbackData
+}
+
+// TelegramSender delivers messages through the Telegram Bot API
+type TelegramSender struct {
+	client *telegram.Client
+}
+
+func NewTelegramSender(token string, baseURL string) *TelegramSender {
+	return &TelegramSender{
+		client: telegram.NewClient(token, baseURL, 15*time.Second),
+	}
+}
+
+func (s *TelegramSender) Send(ctx context.Context, msg *Message) (string, error) {
+	params := map[string]interface{}{
+		"chat_id": msg.Recipient,
+		"text":    msg.Body,
+	}
+	if keyboard := alertKeyboard(msg); keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+
+	var sent telegram.Message
+	if err := s.client.Call(ctx, "sendMessage", params, &sent); err != nil {
+		// 403 means the user blocked the bot or the bot was removed from the chat
+		var apiErr *telegram.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+			return "", fmt.Errorf("%w: %w", ErrEndpointGone, err)
+		}
+		return "", err
+	}
+
+	return strconv.FormatInt(sent.MessageID, 10), nil
+}
+
+// alertKeyboard offers snooze, disable and mute buttons on single alert notifications
+func alertKeyboard(msg *Message) *telegram.InlineKeyboardMarkup {
+	if msg.AlertID == "" {
+		return nil
+	}
+
+	row := []telegram.InlineKeyboardButton{
+		{Text: "Snooze 1h", CallbackData: EncodeAlertAction(AlertActionSnooze, msg.AlertID, "")},
+		{Text: "Disable", CallbackData: EncodeAlertAction(AlertActionDisable, msg.AlertID, "")},
+	}
+
+	if mute := EncodeAlertAction(AlertActionMute, msg.AlertID, msg.Symbol); msg.Symbol != "" && len(mute) <= maxCallbackDataLength {
+		row = append(row, telegram.InlineKeyboardButton{Text: "Mute " + msg.Symbol, CallbackData: mute})
+	}
+
+	return &telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{row}}
+}
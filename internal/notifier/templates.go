@@ -0,0 +1,316 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"alerts-worker/pkg/metrics"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"reflect"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// TemplateVars is the complete set of variables a notification template may reference
+type TemplateVars struct {
+	AlertName       string
+	Symbol          string
+	Price           string
+	Condition       string
+	UserDisplayName string
+}
+
+// SummaryVars is passed to the embedded templates that combine several triggers into one message
+type SummaryVars struct {
+	Count           int
+	UserDisplayName string
+	Alerts          []TemplateVars
+}
+
+var templateVarNames = func() map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(TemplateVars{})
+	for i := 0; i < t.NumField(); i++ {
+		names[t.Field(i).Name] = true
+	}
+	return names
+}()
+
+// ValidateTemplate parses text and rejects it if it references a variable outside TemplateVars
+func ValidateTemplate(text string) error {
+	tmpl, err := template.New("validate").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return validateNode(tmpl.Tree.Root)
+}
+
+func validateNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := validateNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return validateNode(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := validateNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := validateNode(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return validateNode(n.Node)
+	case *parse.FieldNode:
+		if !templateVarNames[n.Ident[0]] {
+			return fmt.Errorf("unknown template variable %q", n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 && !templateVarNames[n.Ident[1]] {
+			return fmt.Errorf("unknown template variable %q", n.Ident[1])
+		}
+	case *parse.IfNode:
+		return validateBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return validateBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return validateBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		return validateNode(n.Pipe)
+	}
+
+	return nil
+}
+
+func validateBranch(n *parse.BranchNode) error {
+	for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := validateNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renderer renders notifications from the notification_templates table, falling back to
+// the English template and then to the embedded defaults
+type Renderer struct {
+	repo    repository.NotificationTemplateRepository
+	logger  *zerolog.Logger
+	metrics *metrics.NotificationMetrics
+	// cache holds nil for combinations without a stored template
+	cache *ttlCache[*models.NotificationTemplate]
+}
+
+func NewRenderer(repo repository.NotificationTemplateRepository, logger *zerolog.Logger, metrics *metrics.NotificationMetrics, cacheTTL time.Duration) *Renderer {
+	return &Renderer{
+		repo:    repo,
+		logger:  logger,
+		metrics: metrics,
+		cache:   newTTLCache[*models.NotificationTemplate](cacheTTL),
+	}
+}
+
+// Render returns the subject and body for trigger on channel
+func (r *Renderer) Render(ctx context.Context, channel models.NotificationChannel, trigger *Trigger) (string, string, error) {
+	subjectText, bodyText, err := r.lookup(ctx, trigger.AlertTypeID, channel, trigger.Locale)
+	if err != nil {
+		return "", "", err
+	}
+
+	return execute(channel, subjectText, bodyText, newTemplateVars(trigger))
+}
+
+// RenderSummary renders several triggers into one message using the embedded templates for kind
+func (r *Renderer) RenderSummary(kind models.OutboxKind, channel models.NotificationChannel, triggers []Trigger) (string, string, error) {
+	subjectText, bodyText, err := defaultTemplate(kind, channel)
+	if err != nil {
+		return "", "", err
+	}
+
+	vars := SummaryVars{Count: len(triggers)}
+	for i := range triggers {
+		vars.Alerts = append(vars.Alerts, newTemplateVars(&triggers[i]))
+		vars.UserDisplayName = triggers[i].UserDisplayName
+	}
+
+	return execute(channel, subjectText, bodyText, vars)
+}
+
+func newTemplateVars(trigger *Trigger) TemplateVars {
+	return TemplateVars{
+		AlertName:       trigger.AlertName,
+		Symbol:          trigger.Symbol,
+		Price:           strconv.FormatFloat(trigger.Price, 'f', -1, 64),
+		Condition:       trigger.Condition,
+		UserDisplayName: trigger.UserDisplayName,
+	}
+}
+
+// execute renders the subject as plain text and the body as HTML for email and plain text elsewhere
+func execute(channel models.NotificationChannel, subjectText string, bodyText string, vars interface{}) (string, string, error) {
+	subject, err := executeText(subjectText, vars)
+	if err != nil {
+		return "", "", err
+	}
+
+	var body string
+	if channel == models.NotificationChannelEmail {
+		body, err = executeHTML(bodyText, vars)
+	} else {
+		body, err = executeText(bodyText, vars)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+func (r *Renderer) lookup(ctx context.Context, alertTypeID string, channel models.NotificationChannel, locale string) (string, string, error) {
+	locales := []string{models.DefaultLocale}
+	if locale != "" && locale != models.DefaultLocale {
+		locales = []string{locale, models.DefaultLocale}
+	}
+
+	for _, l := range locales {
+		tmpl, err := r.load(ctx, alertTypeID, channel, l)
+		if err != nil {
+			return "", "", err
+		}
+		if tmpl == nil {
+			continue
+		}
+
+		if err := ValidateNotificationTemplate(tmpl); err != nil {
+			r.metrics.InvalidTemplatesSkipped.WithLabelValues(string(channel), l).Inc()
+			r.logger.Warn().
+				Err(err).
+				Str("template_id", tmpl.ID).
+				Msg("skipping invalid notification template")
+			continue
+		}
+
+		return tmpl.Subject, tmpl.Body, nil
+	}
+
+	return defaultTemplate(models.OutboxKindTrigger, channel)
+}
+
+func (r *Renderer) load(ctx context.Context, alertTypeID string, channel models.NotificationChannel, locale string) (*models.NotificationTemplate, error) {
+	key := alertTypeID + "|" + string(channel) + "|" + locale
+
+	if tmpl, ok := r.cache.get(key); ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := r.repo.GetNotificationTemplate(ctx, alertTypeID, channel, locale)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tmpl, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification template: %w", err)
+	}
+
+	r.cache.set(key, tmpl)
+
+	return tmpl, nil
+}
+
+// ValidateNotificationTemplate validates the subject and body of a stored template and
+// renders them once with empty variables, which catches what only fails on execution,
+// such as HTML escaping errors in email bodies. Renders skip templates failing it.
+func ValidateNotificationTemplate(tmpl *models.NotificationTemplate) error {
+	if err := ValidateTemplate(tmpl.Subject); err != nil {
+		return fmt.Errorf("subject: %w", err)
+	}
+	if err := ValidateTemplate(tmpl.Body); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	if _, _, err := execute(tmpl.Channel, tmpl.Subject, tmpl.Body, TemplateVars{}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func defaultTemplate(kind models.OutboxKind, channel models.NotificationChannel) (string, string, error) {
+	subject, err := readDefaultTemplate(kind, channel, "subject")
+	if err != nil {
+		return "", "", err
+	}
+	body, err := readDefaultTemplate(kind, channel, "body")
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+// readDefaultTemplate reads templates/<kind>.<channel>.<part>.tmpl, falling back to the kind's default
+func readDefaultTemplate(kind models.OutboxKind, channel models.NotificationChannel, part string) (string, error) {
+	text, err := defaultTemplates.ReadFile(fmt.Sprintf("templates/%s.%s.%s.tmpl", kind, channel, part))
+	if err == nil {
+		return string(text), nil
+	}
+
+	text, err = defaultTemplates.ReadFile(fmt.Sprintf("templates/%s.default.%s.tmpl", kind, part))
+	if err != nil {
+		return "", fmt.Errorf("failed to read default %s template: %w", part, err)
+	}
+
+	return string(text), nil
+}
+
+func executeText(text string, vars interface{}) (string, error) {
+	tmpl, err := template.New("notification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func executeHTML(text string, vars interface{}) (string, error) {
+	tmpl, err := htmltemplate.New("notification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
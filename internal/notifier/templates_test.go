@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"alerts-worker/internal/models"
+	"testing"
+)
+
+func TestValidateNotificationTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    models.NotificationTemplate
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			tmpl: models.NotificationTemplate{Channel: models.NotificationChannelEmail, Subject: "{{.Symbol}} alert", Body: "<p>{{.AlertName}} at {{.Price}}</p>"},
+		},
+		{
+			name:    "unknown variable",
+			tmpl:    models.NotificationTemplate{Channel: models.NotificationChannelTelegram, Subject: "alert", Body: "{{.Ticker}}"},
+			wantErr: true,
+		},
+		{
+			name:    "broken html",
+			tmpl:    models.NotificationTemplate{Channel: models.NotificationChannelEmail, Subject: "alert", Body: `<a href="{{.Symbol}}`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNotificationTemplate(&tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type TestResultStatus string
+
+const (
+	TestResultSent    TestResultStatus = "sent"
+	TestResultFailed  TestResultStatus = "failed"
+	TestResultSkipped TestResultStatus = "skipped"
+)
+
+// TestResult is the outcome of a test notification on one channel
+type TestResult struct {
+	Status            TestResultStatus `json:"status"`
+	ProviderMessageID string           `json:"provider_message_id,omitempty"`
+	Error             string           `json:"error,omitempty"`
+	At                time.Time        `json:"at"`
+}
+
+// TestResultStore keeps test notification results in a Redis hash per request, keyed by
+// channel, for the app to poll
+type TestResultStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewTestResultStore(client *redis.Client, prefix string, ttl time.Duration) *TestResultStore {
+	return &TestResultStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (s *TestResultStore) Key(requestID string) string {
+	return s.prefix + ":" + requestID
+}
+
+// Save stores the result of one channel and refreshes the expiry of the request
+func (s *TestResultStore) Save(ctx context.Context, requestID string, field string, result *TestResult) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal test result: %w", err)
+	}
+
+	key := s.Key(requestID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, field, raw)
+	pipe.Expire(ctx, key, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save test result: %w", err)
+	}
+
+	return nil
+}
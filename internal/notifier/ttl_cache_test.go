@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCacheSweepsExpiredEntriesOnWrite(t *testing.T) {
+	c := newTTLCache[int](10 * time.Millisecond)
+	c.set("a", 1)
+	c.set("b", 2)
+
+	if value, ok := c.get("a"); !ok || value != 1 {
+		t.Fatalf("expected a fresh entry, got %d, %v", value, ok)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("expected the entry to expire")
+	}
+
+	c.set("c", 3)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.entries) != 1 {
+		t.Fatalf("expected expired entries to be swept, %d entries left", len(c.entries))
+	}
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"alerts-worker/internal/repository"
+	"alerts-worker/pkg/webpush"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// WebPushSender delivers messages to every active browser subscription of the user. The
+// outbox recipient for this channel is the user ID.
+type WebPushSender struct {
+	client        *webpush.Client
+	subscriptions repository.WebPushSubscriptionRepository
+	logger        *zerolog.Logger
+	ttl           time.Duration
+}
+
+func NewWebPushSender(client *webpush.Client, subscriptions repository.WebPushSubscriptionRepository, logger *zerolog.Logger) *WebPushSender {
+	return &WebPushSender{
+		client:        client,
+		subscriptions: subscriptions,
+		logger:        logger,
+		ttl:           time.Hour,
+	}
+}
+
+// Send succeeds when at least one subscription accepted the message. Subscriptions the
+// push service reports as gone are expired.
+func (s *WebPushSender) Send(ctx context.Context, msg *Message) (string, error) {
+	subscriptions, err := s.subscriptions.GetActiveWebPushSubscriptions(ctx, msg.Recipient)
+	if err != nil {
+		return "", fmt.Errorf("failed to get web push subscriptions: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"title":    msg.Subject,
+		"body":     msg.Body,
+		"alert_id": msg.AlertID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal web push payload: %w", err)
+	}
+
+	var messageID string
+	var delivered bool
+	var errs []error
+
+	for _, subscription := range subscriptions {
+		location, err := s.client.Send(ctx, &webpush.Subscription{
+			Endpoint: subscription.Endpoint,
+			P256dh:   subscription.P256dh,
+			Auth:     subscription.Auth,
+		}, payload, s.ttl, webpush.UrgencyHigh)
+
+		if errors.Is(err, webpush.ErrSubscriptionGone) {
+			if err := s.subscriptions.ExpireWebPushSubscription(ctx, subscription.ID); err != nil {
+				s.logger.Error().Err(err).Str("subscription_id", subscription.ID).Msg("error expiring web push subscription")
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !delivered {
+			messageID = location
+			delivered = true
+		}
+	}
+
+	if delivered {
+		return messageID, nil
+	}
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	return "", ErrNoRecipients
+}
@@ -0,0 +1,246 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ThrottledError is returned when a provider asked us to hold off sending until RetryAt
+type ThrottledError struct {
+	Provider string
+	RetryAt  time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s rate limited, retry at %s", e.Provider, e.RetryAt.Format(time.RFC3339))
+}
+
+// webhookClient posts JSON to incoming webhooks and remembers per-webhook rate limits so
+// that a throttled webhook is not hit again before its reset
+type webhookClient struct {
+	provider string
+	baseURL  *url.URL
+	client   *http.Client
+
+	mu           sync.Mutex
+	blockedUntil map[string]time.Time
+}
+
+func newWebhookClient(provider string, baseURL string) (*webhookClient, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s base url: %w", provider, err)
+	}
+
+	return &webhookClient{
+		provider:     provider,
+		baseURL:      base,
+		client:       &http.Client{Timeout: 15 * time.Second},
+		blockedUntil: make(map[string]time.Time),
+	}, nil
+}
+
+// resolve keeps the path and query of the stored webhook URL but always targets the
+// configured host, so stored URLs can never point the worker elsewhere
+func (c *webhookClient) resolve(webhookURL string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Path == "" {
+		return "", fmt.Errorf("%w: invalid %s webhook url", ErrEndpointGone, c.provider)
+	}
+
+	resolved := *c.baseURL
+	resolved.Path = c.baseURL.Path + u.Path
+	resolved.RawQuery = u.RawQuery
+
+	return resolved.String(), nil
+}
+
+// post sends body to the webhook and returns the response body of a successful request
+func (c *webhookClient) post(ctx context.Context, webhookURL string, query url.Values, body interface{}) ([]byte, error) {
+	endpoint, err := c.resolve(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	until, blocked := c.blockedUntil[endpoint]
+	if blocked && time.Now().After(until) {
+		delete(c.blockedUntil, endpoint)
+		blocked = false
+	}
+	c.mu.Unlock()
+	if blocked {
+		return nil, &ThrottledError{Provider: c.provider, RetryAt: until}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", c.provider, err)
+	}
+
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", c.provider, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	// Discord announces an exhausted bucket before we hit it
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if resetAfter, ok := parseSeconds(resp.Header.Get("X-RateLimit-Reset-After")); ok {
+			c.block(endpoint, time.Now().Add(resetAfter))
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter, ok := parseSeconds(resp.Header.Get("Retry-After"))
+		if !ok {
+			retryAfter = time.Second
+		}
+		retryAt := time.Now().Add(retryAfter)
+		c.block(endpoint, retryAt)
+		return nil, &ThrottledError{Provider: c.provider, RetryAt: retryAt}
+	// Deleted webhooks answer 404 or 410, revoked or invalid tokens 401 or 403
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone,
+		resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %s webhook returned %d: %s", ErrEndpointGone, c.provider, resp.StatusCode, respBody)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, &StatusError{Provider: c.provider, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return respBody, nil
+}
+
+func (c *webhookClient) block(endpoint string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if until.After(c.blockedUntil[endpoint]) {
+		c.blockedUntil[endpoint] = until
+	}
+}
+
+// truncateText shortens text to at most max characters, ending it with an ellipsis
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// parseSeconds parses the integer or fractional seconds used by rate limit headers
+func parseSeconds(value string) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// slackHeaderMaxLength is the most characters Slack accepts in a header block
+const slackHeaderMaxLength = 150
+
+// SlackSender posts Block Kit messages to Slack incoming webhooks
+type SlackSender struct {
+	client *webhookClient
+}
+
+func NewSlackSender(baseURL string) (*SlackSender, error) {
+	client, err := newWebhookClient("slack", baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &SlackSender{client: client}, nil
+}
+
+func (s *SlackSender) Send(ctx context.Context, msg *Message) (string, error) {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": truncateText(msg.Subject, slackHeaderMaxLength), "emoji": true},
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": msg.Body},
+		},
+	}
+	if msg.Symbol != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "context",
+			"elements": []map[string]interface{}{{"type": "mrkdwn", "text": "*" + msg.Symbol + "*"}},
+		})
+	}
+
+	// Incoming webhooks answer with a plain "ok" and no message ID
+	_, err := s.client.post(ctx, msg.Recipient, nil, map[string]interface{}{
+		"text":   msg.Subject,
+		"blocks": blocks,
+	})
+	return "", err
+}
+
+// discordEmbedColor is the accent color of alert embeds
+const discordEmbedColor = 0xF0B90B
+
+// DiscordSender posts embeds to Discord webhooks
+type DiscordSender struct {
+	client *webhookClient
+}
+
+func NewDiscordSender(baseURL string) (*DiscordSender, error) {
+	client, err := newWebhookClient("discord", baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &DiscordSender{client: client}, nil
+}
+
+func (s *DiscordSender) Send(ctx context.Context, msg *Message) (string, error) {
+	embed := map[string]interface{}{
+		"title":       msg.Subject,
+		"description": msg.Body,
+		"color":       discordEmbedColor,
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if msg.Symbol != "" {
+		embed["footer"] = map[string]string{"text": msg.Symbol}
+	}
+
+	// wait=true makes Discord return the created message
+	respBody, err := s.client.post(ctx, msg.Recipient, url.Values{"wait": {"true"}}, map[string]interface{}{
+		"embeds": []interface{}{embed},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", nil
+	}
+
+	return created.ID, nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type AlertEscalationRepository interface {
+	GetEscalationSteps(ctx context.Context, alertID string) ([]models.AlertEscalationStep, error)
+}
+
+type alertEscalationRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertEscalationRepository(db *gorm.DB) AlertEscalationRepository {
+	return &alertEscalationRepository{db: db}
+}
+
+func (r *alertEscalationRepository) GetEscalationSteps(ctx context.Context, alertID string) ([]models.AlertEscalationStep, error) {
+	var steps []models.AlertEscalationStep
+	err := r.db.WithContext(ctx).Where("alert_id = ?", alertID).Order("position").Find(&steps).Error
+	return steps, err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AlertRepository interface {
+	GetAlert(ctx context.Context, alertID string) (*models.Alert, error)
+	GetActiveAlertsForSymbols(ctx context.Context, alertTypeID string, symbols []string) ([]models.Alert, error)
+	RecordAlertTrigger(ctx context.Context, alertID string, triggeredAt time.Time, tick time.Time) (bool, error)
+	SnoozeAlert(ctx context.Context, alertID string, until time.Time) error
+	DisableAlert(ctx context.Context, alertID string) error
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+func (r *alertRepository) GetAlert(ctx context.Context, alertID string) (*models.Alert, error) {
+	var alert models.Alert
+	err := r.db.WithContext(ctx).Where("id = ?", alertID).First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// GetActiveAlertsForSymbols loads active alerts of a type whose conditions name one of
+// symbols, for evaluating a whole price snapshot in one query. The literal is_active
+// matches the partial idx_alerts_type_symbol index.
+func (r *alertRepository) GetActiveAlertsForSymbols(ctx context.Context, alertTypeID string, symbols []string) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.WithContext(ctx).
+		Where("alert_type_id = ? AND is_active AND symbol IN ?", alertTypeID, symbols).
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// RecordAlertTrigger records a trigger by the tick at tick, unless the alert was already
+// triggered by that tick or a later one. It reports whether the trigger was recorded.
+func (r *alertRepository) RecordAlertTrigger(ctx context.Context, alertID string, triggeredAt time.Time, tick time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ?", alertID).
+		Where("last_triggered_tick IS NULL OR last_triggered_tick < ?", tick).
+		Updates(map[string]interface{}{
+			"last_triggered":      triggeredAt,
+			"last_triggered_tick": tick,
+			"trigger_count":       gorm.Expr("trigger_count + 1"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *alertRepository) SnoozeAlert(ctx context.Context, alertID string, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ?", alertID).
+		Update("snoozed_until", until).Error
+}
+
+func (r *alertRepository) DisableAlert(ctx context.Context, alertID string) error {
+	return r.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ?", alertID).
+		Update("is_active", false).Error
+}
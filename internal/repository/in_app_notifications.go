@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InAppNotificationRepository interface {
+	CreateInAppNotification(ctx context.Context, notification *models.InAppNotification) error
+}
+
+type inAppNotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewInAppNotificationRepository(db *gorm.DB) InAppNotificationRepository {
+	return &inAppNotificationRepository{db: db}
+}
+
+// CreateInAppNotification is idempotent on the notification ID, so a retried delivery
+// does not add a second feed entry
+func (r *inAppNotificationRepository) CreateInAppNotification(ctx context.Context, notification *models.InAppNotification) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoNothing: true,
+		}).
+		Create(notification).Error
+}
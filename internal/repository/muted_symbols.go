@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MutedSymbolRepository interface {
+	MuteSymbol(ctx context.Context, userID string, symbol string) error
+	IsSymbolMuted(ctx context.Context, userID string, symbol string) (bool, error)
+}
+
+type mutedSymbolRepository struct {
+	db *gorm.DB
+}
+
+func NewMutedSymbolRepository(db *gorm.DB) MutedSymbolRepository {
+	return &mutedSymbolRepository{db: db}
+}
+
+func (r *mutedSymbolRepository) MuteSymbol(ctx context.Context, userID string, symbol string) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "symbol"}},
+			DoNothing: true,
+		}).
+		Create(&models.MutedSymbol{ID: uuid.NewString(), UserID: userID, Symbol: symbol}).Error
+}
+
+func (r *mutedSymbolRepository) IsSymbolMuted(ctx context.Context, userID string, symbol string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.MutedSymbol{}).
+		Where("user_id = ? AND symbol = ?", userID, symbol).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationDeliveryRepository interface {
+	SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error
+	ListRecentDeliveries(ctx context.Context, userID string, limit int) ([]models.NotificationDelivery, error)
+}
+
+type notificationDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationDeliveryRepository(db *gorm.DB) NotificationDeliveryRepository {
+	return &notificationDeliveryRepository{db: db}
+}
+
+// SaveDelivery inserts the receipt of an outbox entry or updates it on later attempts
+func (r *notificationDeliveryRepository) SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "outbox_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"provider_message_id", "attempts", "status", "error",
+			"queue_latency_ms", "send_latency_ms", "delivered_at", "updated_at",
+		}),
+	}).Create(delivery).Error
+}
+
+func (r *notificationDeliveryRepository) ListRecentDeliveries(ctx context.Context, userID string, limit int) ([]models.NotificationDelivery, error) {
+	var deliveries []models.NotificationDelivery
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
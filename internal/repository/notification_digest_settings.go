@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type NotificationDigestSettingsRepository interface {
+	GetDigestSettings(ctx context.Context, userID string) ([]models.NotificationDigestSetting, error)
+}
+
+type notificationDigestSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationDigestSettingsRepository(db *gorm.DB) NotificationDigestSettingsRepository {
+	return &notificationDigestSettingsRepository{db: db}
+}
+
+func (r *notificationDigestSettingsRepository) GetDigestSettings(ctx context.Context, userID string) ([]models.NotificationDigestSetting, error) {
+	var settings []models.NotificationDigestSetting
+	err := r.db.WithContext(ctx).Where("user_id = ? AND is_enabled = ?", userID, true).Find(&settings).Error
+	return settings, err
+}
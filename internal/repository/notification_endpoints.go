@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationEndpointRepository interface {
+	GetActiveEndpoints(ctx context.Context, userID string, channel models.NotificationChannel) ([]models.NotificationEndpoint, error)
+	GetEndpointByAddress(ctx context.Context, channel models.NotificationChannel, address string) (*models.NotificationEndpoint, error)
+	SaveEndpoint(ctx context.Context, endpoint *models.NotificationEndpoint) error
+	MarkEndpointSucceeded(ctx context.Context, id string, at time.Time) error
+	DisableEndpoint(ctx context.Context, id string) error
+}
+
+type notificationEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationEndpointRepository(db *gorm.DB) NotificationEndpointRepository {
+	return &notificationEndpointRepository{db: db}
+}
+
+func (r *notificationEndpointRepository) GetActiveEndpoints(ctx context.Context, userID string, channel models.NotificationChannel) ([]models.NotificationEndpoint, error) {
+	var endpoints []models.NotificationEndpoint
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND channel = ? AND is_enabled = ?", userID, channel, true).
+		Order("created_at").
+		Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *notificationEndpointRepository) GetEndpointByAddress(ctx context.Context, channel models.NotificationChannel, address string) (*models.NotificationEndpoint, error) {
+	var endpoint models.NotificationEndpoint
+	err := r.db.WithContext(ctx).Where("channel = ? AND address = ?", channel, address).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// SaveEndpoint inserts the endpoint, or moves an existing address to the endpoint's user
+// and re-enables it
+func (r *notificationEndpointRepository) SaveEndpoint(ctx context.Context, endpoint *models.NotificationEndpoint) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "channel"}, {Name: "address"}},
+			DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "is_enabled", "updated_at"}),
+		}).
+		Create(endpoint).Error
+}
+
+func (r *notificationEndpointRepository) MarkEndpointSucceeded(ctx context.Context, id string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationEndpoint{}).
+		Where("id = ?", id).
+		Update("last_success_at", at).Error
+}
+
+func (r *notificationEndpointRepository) DisableEndpoint(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationEndpoint{}).
+		Where("id = ?", id).
+		Update("is_enabled", false).Error
+}
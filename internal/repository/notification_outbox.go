@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationOutboxRepository interface {
+	CreateOutboxEntries(ctx context.Context, entries []models.NotificationOutbox) error
+	ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]models.NotificationOutbox, error)
+	ReleaseOutboxEntries(ctx context.Context, ids []string, claimedAt time.Time) error
+	MarkOutboxSent(ctx context.Context, id string, claimedAt time.Time) error
+	MarkOutboxFailed(ctx context.Context, id string, claimedAt time.Time, reason string) error
+	RescheduleOutboxEntry(ctx context.Context, id string, claimedAt time.Time, reason string, availableAt time.Time) error
+	SetOutboxStatus(ctx context.Context, id string, claimedAt time.Time, status models.OutboxStatus, reason string) error
+	DeferOutboxEntry(ctx context.Context, id string, claimedAt time.Time, reason string, availableAt time.Time) error
+}
+
+type notificationOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationOutboxRepository(db *gorm.DB) NotificationOutboxRepository {
+	return &notificationOutboxRepository{db: db}
+}
+
+func (r *notificationOutboxRepository) CreateOutboxEntries(ctx context.Context, entries []models.NotificationOutbox) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&entries).Error
+}
+
+// ClaimOutboxEntries locks up to limit due entries with SKIP LOCKED so that concurrent
+// dispatchers never pick the same row, and marks them as processing. Entries left in
+// processing for longer than lease are considered abandoned and are claimed again.
+//
+// The claim time identifies the claim: the updates below only apply while the entry is
+// still claimed at that time, so a dispatcher that outlived its lease can't overwrite
+// the outcome of the dispatcher that claimed the entry after it.
+func (r *notificationOutboxRepository) ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]models.NotificationOutbox, error) {
+	var entries []models.NotificationOutbox
+	// Truncated to the precision postgres stores, so that it compares equal when read back
+	now := time.Now().Truncate(time.Microsecond)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status IN ? AND available_at <= ?) OR (status = ? AND claimed_at < ?)",
+				[]models.OutboxStatus{models.OutboxStatusPending, models.OutboxStatusDeferred}, now,
+				models.OutboxStatusProcessing, now.Add(-lease)).
+			Order("available_at").
+			Limit(limit).
+			Find(&entries).Error
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(entries))
+		for i := range entries {
+			ids[i] = entries[i].ID
+		}
+
+		return tx.Model(&models.NotificationOutbox{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":     models.OutboxStatusProcessing,
+				"claimed_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entries[i].Status = models.OutboxStatusProcessing
+		entries[i].ClaimedAt = &now
+		entries[i].Attempts++
+	}
+	return entries, nil
+}
+
+// ReleaseOutboxEntries hands claimed entries back without counting the attempt, for
+// entries the dispatcher did not get to before its lease ran out
+func (r *notificationOutboxRepository) ReleaseOutboxEntries(ctx context.Context, ids []string, claimedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id IN ? AND claimed_at = ?", ids, claimedAt).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxStatusPending,
+			"claimed_at": nil,
+			"attempts":   gorm.Expr("GREATEST(attempts - 1, 0)"),
+		}).Error
+}
+
+func (r *notificationOutboxRepository) MarkOutboxSent(ctx context.Context, id string, claimedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND claimed_at = ?", id, claimedAt).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxStatusSent,
+			"sent_at":    time.Now(),
+			"last_error": nil,
+		}).Error
+}
+
+func (r *notificationOutboxRepository) MarkOutboxFailed(ctx context.Context, id string, claimedAt time.Time, reason string) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND claimed_at = ?", id, claimedAt).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxStatusFailed,
+			"last_error": reason,
+		}).Error
+}
+
+func (r *notificationOutboxRepository) RescheduleOutboxEntry(ctx context.Context, id string, claimedAt time.Time, reason string, availableAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND claimed_at = ?", id, claimedAt).
+		Updates(map[string]interface{}{
+			"status":       models.OutboxStatusPending,
+			"last_error":   reason,
+			"available_at": availableAt,
+			"claimed_at":   nil,
+		}).Error
+}
+
+func (r *notificationOutboxRepository) SetOutboxStatus(ctx context.Context, id string, claimedAt time.Time, status models.OutboxStatus, reason string) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND claimed_at = ?", id, claimedAt).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"last_error": reason,
+		}).Error
+}
+
+// DeferOutboxEntry parks an entry until availableAt without counting the attempt, for
+// deliveries that were never handed to a provider
+func (r *notificationOutboxRepository) DeferOutboxEntry(ctx context.Context, id string, claimedAt time.Time, reason string, availableAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND claimed_at = ?", id, claimedAt).
+		Updates(map[string]interface{}{
+			"status":       models.OutboxStatusDeferred,
+			"last_error":   reason,
+			"available_at": availableAt,
+			"claimed_at":   nil,
+			"attempts":     gorm.Expr("GREATEST(attempts - 1, 0)"),
+		}).Error
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type NotificationTemplateRepository interface {
+	GetNotificationTemplate(ctx context.Context, alertTypeID string, channel models.NotificationChannel, locale string) (*models.NotificationTemplate, error)
+	ListNotificationTemplates(ctx context.Context) ([]models.NotificationTemplate, error)
+}
+
+type notificationTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationTemplateRepository(db *gorm.DB) NotificationTemplateRepository {
+	return &notificationTemplateRepository{db: db}
+}
+
+func (r *notificationTemplateRepository) GetNotificationTemplate(ctx context.Context, alertTypeID string, channel models.NotificationChannel, locale string) (*models.NotificationTemplate, error) {
+	var tmpl models.NotificationTemplate
+	err := r.db.WithContext(ctx).
+		Where("alert_type_id = ? AND channel = ? AND locale = ?", alertTypeID, channel, locale).
+		First(&tmpl).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+func (r *notificationTemplateRepository) ListNotificationTemplates(ctx context.Context) ([]models.NotificationTemplate, error) {
+	var templates []models.NotificationTemplate
+	err := r.db.WithContext(ctx).
+		Order("alert_type_id, channel, locale").
+		Find(&templates).Error
+	return templates, err
+}
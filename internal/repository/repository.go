@@ -1,17 +1,54 @@
 package repository
 
-import "gorm.io/gorm"
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
 
 type Repository struct {
 	db                       *gorm.DB
+	Users                    UserRepository
+	Alerts                   AlertRepository
+	MutedSymbols             MutedSymbolRepository
+	Subscriptions            SubscriptionRepository
+	VerificationCodes        VerificationCodeRepository
 	NotificationSettings     NotificationSettingsRepository
 	AlertNotificationTargets AlertNotificationTargetRepository
+	AlertEscalations         AlertEscalationRepository
+	NotificationOutbox       NotificationOutboxRepository
+	NotificationTemplates    NotificationTemplateRepository
+	NotificationDigests      NotificationDigestSettingsRepository
+	NotificationDeliveries   NotificationDeliveryRepository
+	WebPushSubscriptions     WebPushSubscriptionRepository
+	NotificationEndpoints    NotificationEndpointRepository
+	InAppNotifications       InAppNotificationRepository
 }
 
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{
 		db:                       db,
+		Users:                    NewUserRepository(db),
+		Alerts:                   NewAlertRepository(db),
+		MutedSymbols:             NewMutedSymbolRepository(db),
+		Subscriptions:            NewSubscriptionRepository(db),
+		VerificationCodes:        NewVerificationCodeRepository(db),
 		NotificationSettings:     NewNotificationSettingsRepository(db),
 		AlertNotificationTargets: NewAlertNotificationTargetRepository(db),
+		AlertEscalations:         NewAlertEscalationRepository(db),
+		NotificationOutbox:       NewNotificationOutboxRepository(db),
+		NotificationTemplates:    NewNotificationTemplateRepository(db),
+		NotificationDigests:      NewNotificationDigestSettingsRepository(db),
+		NotificationDeliveries:   NewNotificationDeliveryRepository(db),
+		WebPushSubscriptions:     NewWebPushSubscriptionRepository(db),
+		NotificationEndpoints:    NewNotificationEndpointRepository(db),
+		InAppNotifications:       NewInAppNotificationRepository(db),
 	}
 }
+
+// Transaction runs fn with a Repository bound to a single database transaction.
+func (r *Repository) Transaction(ctx context.Context, fn func(txRepo *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepository(tx))
+	})
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type SubscriptionRepository interface {
+	GetActiveSubscriptionPlan(ctx context.Context, userID string) (*models.SubscriptionPlan, error)
+}
+
+type subscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
+	return &subscriptionRepository{db: db}
+}
+
+func (r *subscriptionRepository) GetActiveSubscriptionPlan(ctx context.Context, userID string) (*models.SubscriptionPlan, error) {
+	var plan models.SubscriptionPlan
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_subscriptions ON user_subscriptions.plan_id = subscription_plans.id").
+		Where("user_subscriptions.user_id = ? AND user_subscriptions.status IN ?", userID, models.ActiveSubscriptionStatuses).
+		Order("user_subscriptions.created_at DESC").
+		First(&plan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type VerificationCodeRepository interface {
+	GetVerificationCodeForUpdate(ctx context.Context, codeType string, code string) (*models.VerificationCodes, error)
+	MarkVerificationCodeUsed(ctx context.Context, id int64) error
+}
+
+type verificationCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationCodeRepository(db *gorm.DB) VerificationCodeRepository {
+	return &verificationCodeRepository{db: db}
+}
+
+// GetVerificationCodeForUpdate locks the code row so that it can only be consumed once;
+// it must be called inside a transaction
+func (r *verificationCodeRepository) GetVerificationCodeForUpdate(ctx context.Context, codeType string, code string) (*models.VerificationCodes, error) {
+	var verificationCode models.VerificationCodes
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("type = ? AND code = ?", codeType, code).
+		Order("created_at DESC").
+		First(&verificationCode).Error
+	if err != nil {
+		return nil, err
+	}
+	return &verificationCode, nil
+}
+
+func (r *verificationCodeRepository) MarkVerificationCodeUsed(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Model(&models.VerificationCodes{}).
+		Where("id = ?", id).
+		Update("is_used", true).Error
+}
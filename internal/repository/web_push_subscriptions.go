@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"alerts-worker/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebPushSubscriptionRepository interface {
+	GetActiveWebPushSubscriptions(ctx context.Context, userID string) ([]models.WebPushSubscription, error)
+	ExpireWebPushSubscription(ctx context.Context, id string) error
+}
+
+type webPushSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewWebPushSubscriptionRepository(db *gorm.DB) WebPushSubscriptionRepository {
+	return &webPushSubscriptionRepository{db: db}
+}
+
+func (r *webPushSubscriptionRepository) GetActiveWebPushSubscriptions(ctx context.Context, userID string) ([]models.WebPushSubscription, error) {
+	var subscriptions []models.WebPushSubscription
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND expired_at IS NULL", userID).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *webPushSubscriptionRepository) ExpireWebPushSubscription(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&models.WebPushSubscription{}).
+		Where("id = ? AND expired_at IS NULL", id).
+		Update("expired_at", time.Now()).Error
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"alerts-worker/internal/notifier"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const alertSnoozeDuration = time.Hour
+
+var (
+	ErrAlertNotFound = errors.New("alert not found")
+	ErrNotAlertOwner = errors.New("telegram user is not linked to the alert owner")
+	ErrUnknownAction = errors.New("unknown alert action")
+)
+
+// ApplyTelegramAlertAction applies an inline button action after checking that the
+// telegram user pressing it is the one who linked the alert owner's account. The chat
+// is not enough, as anyone in a group chat can press the buttons.
+func (s *Service) ApplyTelegramAlertAction(ctx context.Context, telegramUserID int64, action notifier.AlertAction, alertID string) error {
+	alert, err := s.userRepo.Alerts.GetAlert(ctx, alertID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAlertNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	settings, err := s.getNotificationSettings(ctx, alert.UserID)
+	if err != nil {
+		return err
+	}
+
+	if settings.TelegramUserID == nil || *settings.TelegramUserID != telegramUserID {
+		return ErrNotAlertOwner
+	}
+
+	switch action {
+	case notifier.AlertActionSnooze:
+		err = s.userRepo.Alerts.SnoozeAlert(ctx, alert.ID, time.Now().Add(alertSnoozeDuration))
+	case notifier.AlertActionDisable:
+		err = s.userRepo.Alerts.DisableAlert(ctx, alert.ID)
+	case notifier.AlertActionMute:
+		// The symbol comes from the alert, callback data can be forged
+		var condition markPriceCondition
+		if err := json.Unmarshal([]byte(alert.Conditions), &condition); err != nil || condition.Symbol == "" {
+			return fmt.Errorf("alert %s has no symbol to mute", alert.ID)
+		}
+		err = s.userRepo.MutedSymbols.MuteSymbol(ctx, alert.UserID, condition.Symbol)
+	default:
+		return ErrUnknownAction
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply alert action %s: %w", action, err)
+	}
+
+	return nil
+}
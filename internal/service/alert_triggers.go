@@ -0,0 +1,323 @@
+package service
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/notifier"
+	"alerts-worker/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// errDuplicateTrigger rolls back a trigger by a tick that already triggered the alert
+var errDuplicateTrigger = errors.New("alert was already triggered by this tick")
+
+// TriggerAlert records the trigger on the alert and queues a notification outbox row
+// for every enabled target in the same transaction, so a notification is never lost
+// between evaluating an alert and delivering it. Snoozed alerts, muted symbols and ticks
+// that already triggered the alert are skipped.
+func (s *Service) TriggerAlert(ctx context.Context, alert *models.Alert, markPrice *events.BinanceMarkPriceEvent) error {
+	triggeredAt := time.Now()
+
+	if alert.SnoozedUntil != nil && alert.SnoozedUntil.After(triggeredAt) {
+		return nil
+	}
+
+	muted, err := s.userRepo.MutedSymbols.IsSymbolMuted(ctx, alert.UserID, markPrice.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to check muted symbols: %w", err)
+	}
+	if muted {
+		return nil
+	}
+
+	user, err := s.userRepo.Users.GetUser(ctx, alert.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	settings, err := s.getNotificationSettings(ctx, alert.UserID)
+	if err != nil {
+		return err
+	}
+
+	trigger := &notifier.Trigger{
+		TriggerID:       uuid.NewString(),
+		AlertID:         alert.ID,
+		AlertName:       alert.Name,
+		AlertTypeID:     alert.AlertTypeID,
+		UserID:          alert.UserID,
+		UserDisplayName: user.DisplayName,
+		Locale:          settings.Locale,
+		Symbol:          markPrice.Symbol,
+		Price:           markPrice.Price,
+		Condition:       alert.Conditions,
+		Priority:        alert.Priority,
+		TriggeredAt:     triggeredAt,
+	}
+
+	targets, err := s.userRepo.AlertNotificationTargets.GetAlertNotificationTargets(ctx, alert.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert notification targets: %w", err)
+	}
+
+	steps, err := s.userRepo.AlertEscalations.GetEscalationSteps(ctx, alert.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert escalation steps: %w", err)
+	}
+
+	// Alerts with an escalation policy notify their immediate steps now and schedule the
+	// rest; all others notify every enabled target
+	var channels []models.NotificationChannel
+	var delayed []models.AlertEscalationStep
+	if len(steps) > 0 {
+		for _, step := range steps {
+			if step.DelaySeconds <= 0 {
+				channels = append(channels, step.Channel)
+			} else {
+				delayed = append(delayed, step)
+			}
+		}
+	} else {
+		for _, target := range targets {
+			if target.IsEnabled {
+				channels = append(channels, target.Channel)
+			}
+		}
+	}
+
+	entries, err := s.buildOutboxEntries(ctx, user, settings, targets, trigger, channels)
+	if err != nil {
+		return err
+	}
+
+	// The event is committed with the trigger, so other services learn of every trigger
+	// and only of committed ones
+	if s.triggerEvents {
+		entry, err := triggeredEventEntry(alert, user, trigger, markPrice)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, *entry)
+	}
+
+	// Ticks without a timestamp are deduplicated by the time of the trigger
+	tick := triggeredAt
+	if markPrice.Timestamp > 0 {
+		tick = time.UnixMilli(markPrice.Timestamp)
+	}
+
+	err = s.userRepo.Transaction(ctx, func(txRepo *repository.Repository) error {
+		recorded, err := txRepo.Alerts.RecordAlertTrigger(ctx, alert.ID, triggeredAt, tick)
+		if err != nil {
+			return fmt.Errorf("failed to record alert trigger: %w", err)
+		}
+		if !recorded {
+			return errDuplicateTrigger
+		}
+
+		if err := txRepo.NotificationOutbox.CreateOutboxEntries(ctx, entries); err != nil {
+			return fmt.Errorf("failed to create outbox entries: %w", err)
+		}
+
+		return nil
+	})
+	if errors.Is(err, errDuplicateTrigger) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Scheduled only once the trigger is committed, so that no step fires for a trigger
+	// that never happened. The trigger can't be retried from here, as a redelivery is
+	// dropped as a duplicate, so a failure only loses the escalation.
+	if err := s.scheduleEscalation(context.WithoutCancel(ctx), trigger, delayed); err != nil {
+		log.Error().Err(err).Str("alert_id", alert.ID).Str("trigger_id", trigger.TriggerID).Msg("error scheduling escalation")
+	}
+
+	return nil
+}
+
+// triggeredEventEntry creates the outbox row of the alert-triggered event of a trigger.
+// The event ID is the trigger ID, which consumers use to drop redelivered events.
+func triggeredEventEntry(
+	alert *models.Alert,
+	user *models.Users,
+	trigger *notifier.Trigger,
+	markPrice *events.BinanceMarkPriceEvent) (*models.NotificationOutbox, error) {
+
+	event := &events.Event{
+		ID:   trigger.TriggerID,
+		Type: events.EventTypeAlertTriggered,
+		Data: &events.AlertTriggeredEvent{
+			SchemaVersion: events.AlertTriggeredSchemaVersion,
+			TriggerID:     trigger.TriggerID,
+			TriggeredAt:   trigger.TriggeredAt,
+			Alert: events.AlertSnapshot{
+				ID:           alert.ID,
+				Name:         alert.Name,
+				AlertTypeID:  alert.AlertTypeID,
+				Priority:     string(alert.Priority),
+				Conditions:   alert.Conditions,
+				TriggerCount: alert.TriggerCount + 1,
+			},
+			User: events.UserSnapshot{
+				ID:          user.ID,
+				DisplayName: user.DisplayName,
+			},
+			MarkPrice: *markPrice,
+		},
+		CreatedAt: trigger.TriggeredAt,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal alert triggered event: %w", err)
+	}
+
+	return &models.NotificationOutbox{
+		ID:          uuid.NewString(),
+		AlertID:     alert.ID,
+		UserID:      alert.UserID,
+		Channel:     models.NotificationChannelEvents,
+		Kind:        models.OutboxKindAlertTriggered,
+		Payload:     string(payload),
+		Status:      models.OutboxStatusPending,
+		AvailableAt: trigger.TriggeredAt,
+	}, nil
+}
+
+// buildOutboxEntries creates one pending outbox row per channel the user can be reached on
+func (s *Service) buildOutboxEntries(
+	ctx context.Context,
+	user *models.Users,
+	settings *models.UserNotificationSettings,
+	targets []models.AlertNotificationTarget,
+	trigger *notifier.Trigger,
+	channels []models.NotificationChannel) ([]models.NotificationOutbox, error) {
+
+	payload, err := json.Marshal(trigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trigger: %w", err)
+	}
+
+	var entries []models.NotificationOutbox
+	for _, channel := range channels {
+		recipient := resolveRecipient(user, settings, targets, channel)
+		if recipient == "" {
+			reachable, err := s.hasActiveEndpoints(ctx, settings, channel)
+			if err != nil {
+				return nil, err
+			}
+			if !reachable {
+				continue
+			}
+		}
+
+		entries = append(entries, models.NotificationOutbox{
+			ID:          uuid.NewString(),
+			AlertID:     trigger.AlertID,
+			UserID:      trigger.UserID,
+			Channel:     channel,
+			Kind:        models.OutboxKindTrigger,
+			Recipient:   recipient,
+			Payload:     string(payload),
+			Status:      models.OutboxStatusPending,
+			AvailableAt: time.Now(),
+		})
+	}
+
+	return entries, nil
+}
+
+// getNotificationSettings falls back to the model defaults for users who never saved settings
+func (s *Service) getNotificationSettings(ctx context.Context, userID string) (*models.UserNotificationSettings, error) {
+	settings, err := s.userRepo.NotificationSettings.GetUserNotificationSettings(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.UserNotificationSettings{UserID: userID, EmailEnabled: true, InAppEnabled: true, Locale: models.DefaultLocale}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// hasActiveEndpoints reports whether the user registered endpoints for an enabled channel
+// that has no address in the notification settings
+func (s *Service) hasActiveEndpoints(ctx context.Context, settings *models.UserNotificationSettings, channel models.NotificationChannel) (bool, error) {
+	switch channel {
+	case models.NotificationChannelTelegram:
+		if !settings.TelegramEnabled {
+			return false, nil
+		}
+	case models.NotificationChannelPush:
+		if !settings.PushEnabled {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	endpoints, err := s.userRepo.NotificationEndpoints.GetActiveEndpoints(ctx, settings.UserID, channel)
+	if err != nil {
+		return false, fmt.Errorf("failed to get notification endpoints: %w", err)
+	}
+
+	return len(endpoints) > 0, nil
+}
+
+// resolveRecipient returns the channel address for the user, or an empty string when
+// the channel is disabled or not configured
+func resolveRecipient(
+	user *models.Users,
+	settings *models.UserNotificationSettings,
+	targets []models.AlertNotificationTarget,
+	channel models.NotificationChannel) string {
+
+	switch channel {
+	case models.NotificationChannelEmail:
+		if settings.EmailEnabled {
+			return user.Email
+		}
+	case models.NotificationChannelTelegram:
+		if !settings.TelegramEnabled {
+			return ""
+		}
+		// Only a linked chat can be messaged; the bot API can't reach a user by handle
+		if settings.TelegramChatID != nil {
+			return strconv.FormatInt(*settings.TelegramChatID, 10)
+		}
+	case models.NotificationChannelPush:
+		if settings.PushEnabled && settings.DeviceToken != nil {
+			return *settings.DeviceToken
+		}
+	case models.NotificationChannelSlack, models.NotificationChannelDiscord:
+		// Webhooks are configured per alert target rather than per user
+		for _, target := range targets {
+			if target.Channel == channel && target.IsEnabled && target.WebhookURL != nil {
+				return *target.WebhookURL
+			}
+		}
+	case models.NotificationChannelInApp:
+		if settings.InAppEnabled {
+			return user.ID
+		}
+	case models.NotificationChannelWebPush:
+		// Delivered to all of the user's browser subscriptions
+		if settings.WebPushEnabled {
+			return user.ID
+		}
+	}
+
+	return ""
+}
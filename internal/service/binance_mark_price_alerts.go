@@ -1 +1,203 @@
 package service
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Mark price operators fire once when the price crosses the level, not on every tick
+// beyond it
+const (
+	markPriceOperatorAbove   = "above"
+	markPriceOperatorBelow   = "below"
+	markPriceOperatorCrosses = "crosses"
+)
+
+// markPriceCondition is the Conditions JSON of a mark price alert
+type markPriceCondition struct {
+	Symbol   string  `json:"symbol"`
+	Operator string  `json:"operator"`
+	Value    float64 `json:"value"`
+}
+
+// markPriceWindow summarizes the ticks of one symbol since the last evaluation
+type markPriceWindow struct {
+	last *events.BinanceMarkPriceEvent
+	high float64
+	low  float64
+}
+
+// crossed reports whether the price moved across the level between previous and the window
+func (c *markPriceCondition) crossed(previous float64, window *markPriceWindow) bool {
+	up := previous < c.Value && window.high >= c.Value
+	down := previous > c.Value && window.low <= c.Value
+
+	switch c.Operator {
+	case markPriceOperatorAbove:
+		return up
+	case markPriceOperatorBelow:
+		return down
+	case markPriceOperatorCrosses:
+		return up || down
+	}
+	return false
+}
+
+// WithMarkPriceStore enables EvaluateMarkPrices, keeping the last evaluated price per
+// symbol in the hash key
+func WithMarkPriceStore(client *redis.Client, key string) func(*Service) {
+	return func(s *Service) {
+		s.lastPrices = client
+		s.lastPricesKey = key
+	}
+}
+
+// EvaluateMarkPrices triggers the mark price alerts whose level was crossed by prices,
+// which may hold several ticks per symbol. All symbols are evaluated with one alert query
+// and one read and write of the last prices. Failures of single symbols are returned as
+// SymbolErrors.
+func (s *Service) EvaluateMarkPrices(ctx context.Context, prices []events.BinanceMarkPriceEvent) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	if s.lastPrices == nil {
+		return errors.New("mark price evaluation is not configured")
+	}
+
+	windows := summarizeMarkPrices(prices)
+	symbols := make([]string, 0, len(windows))
+	for symbol := range windows {
+		symbols = append(symbols, symbol)
+	}
+
+	values, err := s.lastPrices.HMGet(ctx, s.lastPricesKey, symbols...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get last mark prices: %w", err)
+	}
+
+	previous := make(map[string]float64, len(symbols))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if price, err := strconv.ParseFloat(raw, 64); err == nil {
+			previous[symbols[i]] = price
+		}
+	}
+
+	alerts, err := s.userRepo.Alerts.GetActiveAlertsForSymbols(ctx, models.AlertTypeMarkPrice, symbols)
+	if err != nil {
+		return fmt.Errorf("failed to get mark price alerts: %w", err)
+	}
+
+	failed := make(SymbolErrors)
+
+	for i := range alerts {
+		alert := &alerts[i]
+
+		var condition markPriceCondition
+		if err := json.Unmarshal([]byte(alert.Conditions), &condition); err != nil {
+			log.Warn().Err(err).Str("alert_id", alert.ID).Msg("invalid mark price alert conditions")
+			continue
+		}
+
+		window, ok := windows[condition.Symbol]
+		if !ok {
+			continue
+		}
+		// The first price seen for a symbol has nothing to cross from
+		last, ok := previous[condition.Symbol]
+		if !ok || !condition.crossed(last, window) {
+			continue
+		}
+
+		// A retried snapshot must not trigger the alert twice. TriggerAlert checks this
+		// again when recording the trigger.
+		if window.last.Timestamp > 0 && alert.LastTriggeredTick != nil && !alert.LastTriggeredTick.Before(time.UnixMilli(window.last.Timestamp)) {
+			continue
+		}
+
+		if err := s.TriggerAlert(ctx, alert, window.last); err != nil {
+			failed[condition.Symbol] = errors.Join(failed[condition.Symbol], fmt.Errorf("failed to trigger alert %s: %w", alert.ID, err))
+		}
+	}
+
+	// Symbols with a failed trigger keep their previous price, so that a retry detects
+	// the crossing again
+	updates := make(map[string]interface{}, len(windows))
+	for symbol, window := range windows {
+		if _, ok := failed[symbol]; !ok {
+			updates[symbol] = strconv.FormatFloat(window.last.Price, 'f', -1, 64)
+		}
+	}
+	if len(updates) > 0 {
+		if err := s.lastPrices.HSet(ctx, s.lastPricesKey, updates).Err(); err != nil {
+			for symbol := range updates {
+				failed[symbol] = fmt.Errorf("failed to save last mark prices: %w", err)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// SymbolErrors is returned by EvaluateMarkPrices once the alerts were loaded, with the
+// error of every symbol that failed, so that callers evaluating the ticks of several
+// messages only fail those of the failed symbols
+type SymbolErrors map[string]error
+
+func (e SymbolErrors) Error() string {
+	return errors.Join(e.Unwrap()...).Error()
+}
+
+// Unwrap returns the errors ordered by symbol
+func (e SymbolErrors) Unwrap() []error {
+	symbols := make([]string, 0, len(e))
+	for symbol := range e {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	errs := make([]error, len(symbols))
+	for i, symbol := range symbols {
+		errs[i] = e[symbol]
+	}
+	return errs
+}
+
+// summarizeMarkPrices groups ticks by symbol, keeping the latest tick and the price range
+func summarizeMarkPrices(prices []events.BinanceMarkPriceEvent) map[string]*markPriceWindow {
+	windows := make(map[string]*markPriceWindow)
+
+	for i := range prices {
+		price := &prices[i]
+
+		window, ok := windows[price.Symbol]
+		if !ok {
+			windows[price.Symbol] = &markPriceWindow{last: price, high: price.Price, low: price.Price}
+			continue
+		}
+
+		if price.Timestamp >= window.last.Timestamp {
+			window.last = price
+		}
+		window.high = max(window.high, price.Price)
+		window.low = min(window.low, price.Price)
+	}
+
+	return windows
+}
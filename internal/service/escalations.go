@@ -0,0 +1,259 @@
+package service
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/notifier"
+	"alerts-worker/pkg/delayqueue"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// escalationJob is a delayed escalation step of a single trigger
+type escalationJob struct {
+	Position int                        `json:"position"`
+	Channel  models.NotificationChannel `json:"channel"`
+	Trigger  notifier.Trigger           `json:"trigger"`
+	// Attempts counts the failed attempts to fire the step
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// scheduleEscalation queues the delayed steps of trigger, grouped by alert so that an
+// acknowledgement can cancel them
+func (s *Service) scheduleEscalation(ctx context.Context, trigger *notifier.Trigger, steps []models.AlertEscalationStep) error {
+	for _, step := range steps {
+		payload, err := json.Marshal(escalationJob{
+			Position: step.Position,
+			Channel:  step.Channel,
+			Trigger:  *trigger,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal escalation step: %w", err)
+		}
+
+		id := trigger.TriggerID + ":" + strconv.Itoa(step.Position)
+		at := trigger.TriggeredAt.Add(time.Duration(step.DelaySeconds) * time.Second)
+
+		if err := s.escalations.Schedule(ctx, trigger.AlertID, id, payload, at); err != nil {
+			_, _ = s.cancelEscalation(ctx, trigger.AlertID, trigger.TriggerID)
+			return fmt.Errorf("failed to schedule escalation step %d: %w", step.Position, err)
+		}
+	}
+
+	return nil
+}
+
+// cancelEscalation cancels the pending steps of one trigger, or of every trigger of the
+// alert when triggerID is empty
+func (s *Service) cancelEscalation(ctx context.Context, alertID string, triggerID string) (int64, error) {
+	var match func(id string) bool
+	if triggerID != "" {
+		match = func(id string) bool {
+			return strings.HasPrefix(id, triggerID+":")
+		}
+	}
+
+	return s.escalations.Cancel(ctx, alertID, match)
+}
+
+// AcknowledgeAlert stops the escalation of an alert the user has seen
+func (s *Service) AcknowledgeAlert(ctx context.Context, ack *events.AlertAcknowledgedEvent) error {
+	alert, err := s.userRepo.Alerts.GetAlert(ctx, ack.AlertID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert: %w", err)
+	}
+	if ack.UserID != "" && alert.UserID != ack.UserID {
+		return fmt.Errorf("alert %s does not belong to user %s", ack.AlertID, ack.UserID)
+	}
+
+	if _, err := s.cancelEscalation(ctx, alert.ID, ack.TriggerID); err != nil {
+		return fmt.Errorf("failed to cancel escalation: %w", err)
+	}
+
+	return nil
+}
+
+const (
+	// escalationLease is how long a claimed escalation step may take to fire before
+	// another instance fires it
+	escalationLease = time.Minute
+	// escalationMaxAttempts is how often a failing step is tried before it is dropped
+	escalationMaxAttempts = 5
+	escalationRetryDelay  = 30 * time.Second
+)
+
+// FireDueEscalations queues notifications for escalation steps whose delay has passed and
+// returns how many steps were processed
+func (s *Service) FireDueEscalations(ctx context.Context, limit int) (int, error) {
+	jobs, err := s.escalations.Claim(ctx, time.Now(), limit, escalationLease)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for i := range jobs {
+		job := &jobs[i]
+
+		var step escalationJob
+		if err := json.Unmarshal(job.Payload, &step); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unmarshal escalation step %s: %w", job.ID, err))
+			// A step that can't be read would never fire
+			if ackErr := s.escalations.Ack(ctx, job); ackErr != nil {
+				errs = append(errs, ackErr)
+			}
+			continue
+		}
+
+		if err := s.fireEscalationStep(ctx, &step); err != nil {
+			errs = append(errs, err)
+			if retryErr := s.retryEscalationStep(ctx, job, &step, err); retryErr != nil {
+				errs = append(errs, retryErr)
+			}
+			continue
+		}
+
+		// Until acked the step fires again once its lease runs out
+		if err := s.escalations.Ack(context.WithoutCancel(ctx), job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return len(jobs), errors.Join(errs...)
+}
+
+// retryEscalationStep puts a failed step back so that a transient failure does not end
+// the escalation. Steps of deleted alerts or users, and steps out of attempts, are dropped.
+func (s *Service) retryEscalationStep(ctx context.Context, job *delayqueue.Job, step *escalationJob, cause error) error {
+	step.Attempts++
+	if errors.Is(cause, gorm.ErrRecordNotFound) || step.Attempts >= escalationMaxAttempts {
+		return s.escalations.Ack(context.WithoutCancel(ctx), job)
+	}
+
+	payload, err := json.Marshal(step)
+	if err != nil {
+		return fmt.Errorf("failed to marshal escalation step: %w", err)
+	}
+
+	at := time.Now().Add(time.Duration(step.Attempts) * escalationRetryDelay)
+	return s.escalations.Schedule(ctx, job.Group, job.ID, payload, at)
+}
+
+func (s *Service) fireEscalationStep(ctx context.Context, step *escalationJob) error {
+	alert, err := s.userRepo.Alerts.GetAlert(ctx, step.Trigger.AlertID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert: %w", err)
+	}
+	if !alert.IsActive {
+		return nil
+	}
+
+	user, err := s.userRepo.Users.GetUser(ctx, alert.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	settings, err := s.getNotificationSettings(ctx, alert.UserID)
+	if err != nil {
+		return err
+	}
+
+	targets, err := s.userRepo.AlertNotificationTargets.GetAlertNotificationTargets(ctx, alert.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert notification targets: %w", err)
+	}
+
+	entries, err := s.buildOutboxEntries(ctx, user, settings, targets, &step.Trigger, []models.NotificationChannel{step.Channel})
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.NotificationOutbox.CreateOutboxEntries(ctx, entries); err != nil {
+		return fmt.Errorf("failed to create escalation outbox entries: %w", err)
+	}
+
+	return nil
+}
+
+// Escalator periodically fires escalation steps that became due
+type Escalator struct {
+	alertService AlertService
+	logger       *zerolog.Logger
+	pollInterval time.Duration
+	batchSize    int
+	wg           sync.WaitGroup
+	running      atomic.Bool
+	cancelFunc   context.CancelFunc
+}
+
+func NewEscalator(alertService AlertService, logger *zerolog.Logger, pollInterval time.Duration, batchSize int) *Escalator {
+	return &Escalator{
+		alertService: alertService,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+func (e *Escalator) Start(ctx context.Context) error {
+	if !e.running.CompareAndSwap(false, true) {
+		return errors.New("already running")
+	}
+
+	ctx, e.cancelFunc = context.WithCancel(ctx)
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.run(ctx)
+	}()
+
+	return nil
+}
+
+func (e *Escalator) Stop(timeout time.Duration) {
+	if e.cancelFunc != nil {
+		e.cancelFunc()
+	}
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+		e.logger.Warn().Msg("escalator forced to stop due to timeout")
+	}
+}
+
+func (e *Escalator) run(ctx context.Context) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info().Msg("escalator shutting down")
+			return
+		case <-ticker.C:
+			fired, err := e.alertService.FireDueEscalations(ctx, e.batchSize)
+			if err != nil && ctx.Err() == nil {
+				e.logger.Error().Err(err).Msg("error firing escalation steps")
+			}
+			if fired > 0 {
+				e.logger.Debug().Int("steps", fired).Msg("escalation steps fired")
+			}
+		}
+	}
+}
@@ -1,3 +1,18 @@
 package service
 
-type AlertService interface{}
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/notifier"
+	"context"
+)
+
+type AlertService interface {
+	TriggerAlert(ctx context.Context, alert *models.Alert, markPrice *events.BinanceMarkPriceEvent) error
+	EvaluateMarkPrices(ctx context.Context, prices []events.BinanceMarkPriceEvent) error
+	AcknowledgeAlert(ctx context.Context, ack *events.AlertAcknowledgedEvent) error
+	FireDueEscalations(ctx context.Context, limit int) (int, error)
+	LinkTelegramChat(ctx context.Context, code string, chatID int64, telegramUserID int64) error
+	SendTestNotification(ctx context.Context, req *events.TestNotificationEvent) error
+	ApplyTelegramAlertAction(ctx context.Context, telegramUserID int64, action notifier.AlertAction, alertID string) error
+}
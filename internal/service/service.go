@@ -1,11 +1,43 @@
 package service
 
-import "alerts-worker/internal/repository"
+import (
+	"alerts-worker/internal/notifier"
+	"alerts-worker/internal/repository"
+	"alerts-worker/pkg/delayqueue"
+
+	"github.com/redis/go-redis/v9"
+)
 
 type Service struct {
-	userRepo *repository.Repository
+	userRepo    *repository.Repository
+	escalations *delayqueue.DelayQueue
+	senders     notifier.Senders
+	renderer    *notifier.Renderer
+	testResults *notifier.TestResultStore
+	// triggerEvents queues an alert-triggered event for other services with every trigger
+	triggerEvents bool
+
+	lastPrices    *redis.Client
+	lastPricesKey string
+}
+
+func New(userRepo *repository.Repository, escalations *delayqueue.DelayQueue, options ...func(*Service)) *Service {
+	s := &Service{
+		userRepo:    userRepo,
+		escalations: escalations,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-func New(userRepo *repository.Repository) *Service {
-	return &Service{userRepo: userRepo}
+// WithTriggerEvents queues an alert-triggered event with every trigger, which the outbox
+// dispatcher publishes through the sender of the events channel
+func WithTriggerEvents() func(*Service) {
+	return func(s *Service) {
+		s.triggerEvents = true
+	}
 }
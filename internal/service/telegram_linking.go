@@ -0,0 +1,130 @@
+package service
+
+import (
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidLinkCode = errors.New("invalid telegram link code")
+	ErrLinkCodeExpired = errors.New("telegram link code has expired")
+	ErrLinkCodeUsed    = errors.New("telegram link code was already used")
+)
+
+// LinkTelegramChat consumes a telegram link code issued by the app and registers the chat
+// it was sent from as a telegram endpoint of the owner. The first linked chat is also
+// stored in the notification settings, along with the telegram user who sent the code,
+// who is the one allowed to act on alerts from inline buttons.
+func (s *Service) LinkTelegramChat(ctx context.Context, code string, chatID int64, telegramUserID int64) error {
+	return s.userRepo.Transaction(ctx, func(txRepo *repository.Repository) error {
+		verificationCode, err := txRepo.VerificationCodes.GetVerificationCodeForUpdate(ctx, models.VerificationCodeTypeTelegramLink, code)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidLinkCode
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get verification code: %w", err)
+		}
+
+		if verificationCode.IsUsed {
+			return ErrLinkCodeUsed
+		}
+		if time.Now().After(verificationCode.ExpiryAt) {
+			return ErrLinkCodeExpired
+		}
+
+		if err := txRepo.VerificationCodes.MarkVerificationCodeUsed(ctx, verificationCode.ID); err != nil {
+			return fmt.Errorf("failed to mark verification code as used: %w", err)
+		}
+
+		settings, err := txRepo.NotificationSettings.GetUserNotificationSettings(ctx, verificationCode.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			settings = &models.UserNotificationSettings{
+				ID:           uuid.NewString(),
+				UserID:       verificationCode.UserID,
+				EmailEnabled: true,
+				InAppEnabled: true,
+				Locale:       models.DefaultLocale,
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get notification settings: %w", err)
+		}
+
+		if settings.TelegramChatID == nil {
+			settings.TelegramChatID = &chatID
+		}
+		settings.TelegramUserID = &telegramUserID
+		settings.TelegramEnabled = true
+
+		if err := txRepo.NotificationSettings.CreateOrUpdateNotificationSettings(ctx, settings); err != nil {
+			return fmt.Errorf("failed to save notification settings: %w", err)
+		}
+
+		if err := unlinkPreviousTelegramOwner(ctx, txRepo, verificationCode.UserID, chatID, telegramUserID); err != nil {
+			return err
+		}
+
+		err = txRepo.NotificationEndpoints.SaveEndpoint(ctx, &models.NotificationEndpoint{
+			ID:        uuid.NewString(),
+			UserID:    verificationCode.UserID,
+			Channel:   models.NotificationChannelTelegram,
+			Address:   strconv.FormatInt(chatID, 10),
+			Platform:  models.EndpointPlatformTelegram,
+			IsEnabled: true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save telegram endpoint: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// unlinkPreviousTelegramOwner clears the chat from the settings of the user it was linked
+// to before, when it moves to another user, so it no longer receives their alerts
+func unlinkPreviousTelegramOwner(ctx context.Context, txRepo *repository.Repository, userID string, chatID int64, telegramUserID int64) error {
+	endpoint, err := txRepo.NotificationEndpoints.GetEndpointByAddress(ctx, models.NotificationChannelTelegram, strconv.FormatInt(chatID, 10))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get telegram endpoint: %w", err)
+	}
+	if endpoint.UserID == userID {
+		return nil
+	}
+
+	previous, err := txRepo.NotificationSettings.GetUserNotificationSettings(ctx, endpoint.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get notification settings of previous owner: %w", err)
+	}
+
+	changed := false
+	if previous.TelegramChatID != nil && *previous.TelegramChatID == chatID {
+		previous.TelegramChatID = nil
+		changed = true
+	}
+	// The telegram account moved along with the chat
+	if previous.TelegramUserID != nil && *previous.TelegramUserID == telegramUserID {
+		previous.TelegramUserID = nil
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := txRepo.NotificationSettings.CreateOrUpdateNotificationSettings(ctx, previous); err != nil {
+		return fmt.Errorf("failed to save notification settings of previous owner: %w", err)
+	}
+	return nil
+}
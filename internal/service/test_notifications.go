@@ -0,0 +1,177 @@
+package service
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/internal/models"
+	"alerts-worker/internal/notifier"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// testResultDoneField marks a test request as finished once every channel reported
+const testResultDoneField = "done"
+
+// userLevelChannels can be tested without an alert, since their address is on the user
+var userLevelChannels = []models.NotificationChannel{
+	models.NotificationChannelEmail,
+	models.NotificationChannelTelegram,
+	models.NotificationChannelPush,
+	models.NotificationChannelWebPush,
+	models.NotificationChannelInApp,
+}
+
+// WithTestNotifications enables SendTestNotification
+func WithTestNotifications(senders notifier.Senders, renderer *notifier.Renderer, results *notifier.TestResultStore) func(*Service) {
+	return func(s *Service) {
+		s.senders = senders
+		s.renderer = renderer
+		s.testResults = results
+	}
+}
+
+// SendTestNotification renders a sample trigger and sends it directly through every enabled
+// target of the alert, or every enabled channel of the user when no alert is given. Each
+// channel's outcome is written to the test result store. Delivery failures are results,
+// not errors, so a test is never sent twice by an event retry.
+func (s *Service) SendTestNotification(ctx context.Context, req *events.TestNotificationEvent) error {
+	if s.testResults == nil {
+		return errors.New("test notifications are not configured")
+	}
+
+	user, err := s.userRepo.Users.GetUser(ctx, req.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.finishTest(ctx, req.RequestID, "user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	settings, err := s.getNotificationSettings(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	trigger := &notifier.Trigger{
+		TriggerID:       "test-" + req.RequestID,
+		AlertName:       "Test alert",
+		AlertTypeID:     "test",
+		UserID:          user.ID,
+		UserDisplayName: user.DisplayName,
+		Locale:          settings.Locale,
+		Symbol:          "BTCUSDT",
+		Price:           65000,
+		Condition:       "test notification",
+		Priority:        models.AlertPriorityNormal,
+		TriggeredAt:     time.Now(),
+	}
+
+	var targets []models.AlertNotificationTarget
+	channels := userLevelChannels
+
+	if req.AlertID != "" {
+		alert, err := s.userRepo.Alerts.GetAlert(ctx, req.AlertID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && alert.UserID != user.ID) {
+			return s.finishTest(ctx, req.RequestID, "alert not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get alert: %w", err)
+		}
+
+		targets, err = s.userRepo.AlertNotificationTargets.GetAlertNotificationTargets(ctx, alert.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get alert notification targets: %w", err)
+		}
+
+		trigger.AlertName = alert.Name
+		trigger.AlertTypeID = alert.AlertTypeID
+		trigger.Priority = alert.Priority
+
+		channels = nil
+		for _, target := range targets {
+			if target.IsEnabled {
+				channels = append(channels, target.Channel)
+			}
+		}
+	}
+
+	for _, channel := range channels {
+		result := s.sendTest(ctx, user, settings, targets, trigger, channel)
+		if err := s.testResults.Save(ctx, req.RequestID, string(channel), result); err != nil {
+			return err
+		}
+	}
+
+	return s.finishTest(ctx, req.RequestID, "")
+}
+
+func (s *Service) sendTest(
+	ctx context.Context,
+	user *models.Users,
+	settings *models.UserNotificationSettings,
+	targets []models.AlertNotificationTarget,
+	trigger *notifier.Trigger,
+	channel models.NotificationChannel) *notifier.TestResult {
+
+	result := func(status notifier.TestResultStatus, providerID string, err error) *notifier.TestResult {
+		r := &notifier.TestResult{Status: status, ProviderMessageID: providerID, At: time.Now()}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		return r
+	}
+
+	recipient := resolveRecipient(user, settings, targets, channel)
+	if recipient == "" {
+		reachable, err := s.hasActiveEndpoints(ctx, settings, channel)
+		if err != nil {
+			return result(notifier.TestResultFailed, "", err)
+		}
+		if !reachable {
+			return result(notifier.TestResultSkipped, "", errors.New("channel is disabled or not configured"))
+		}
+	}
+
+	sender, ok := s.senders[channel]
+	if !ok {
+		return result(notifier.TestResultFailed, "", notifier.ErrNoSender)
+	}
+
+	subject, body, err := s.renderer.Render(ctx, channel, trigger)
+	if err != nil {
+		return result(notifier.TestResultFailed, "", err)
+	}
+
+	// AlertID is left empty so that no alert action buttons are attached to the test
+	providerID, err := sender.Send(ctx, &notifier.Message{
+		UserID:    user.ID,
+		Symbol:    trigger.Symbol,
+		Channel:   channel,
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      body,
+		HTML:      channel == models.NotificationChannelEmail,
+	})
+	if err != nil {
+		return result(notifier.TestResultFailed, "", err)
+	}
+
+	return result(notifier.TestResultSent, providerID, nil)
+}
+
+// finishTest marks the request as done, with an error when the test could not run at all
+func (s *Service) finishTest(ctx context.Context, requestID string, reason string) error {
+	status := notifier.TestResultSent
+	if reason != "" {
+		status = notifier.TestResultFailed
+	}
+
+	return s.testResults.Save(ctx, requestID, testResultDoneField, &notifier.TestResult{
+		Status: status,
+		Error:  reason,
+		At:     time.Now(),
+	})
+}
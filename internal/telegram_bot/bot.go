@@ -0,0 +1,251 @@
+package telegram_bot
+
+import (
+	"alerts-worker/internal/notifier"
+	"alerts-worker/internal/service"
+	"alerts-worker/pkg/telegram"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Bot consumes inbound Telegram updates, either by long polling or through ServeHTTP
+// when the Bot API is configured with a webhook
+type Bot struct {
+	client       *telegram.Client
+	alertService service.AlertService
+	logger       *zerolog.Logger
+	secretToken  string
+	pollTimeout  time.Duration
+	wg           sync.WaitGroup
+	running      atomic.Bool
+	cancelFunc   context.CancelFunc
+}
+
+func NewBot(client *telegram.Client, alertService service.AlertService, logger *zerolog.Logger, secretToken string) *Bot {
+	return &Bot{
+		client:       client,
+		alertService: alertService,
+		logger:       logger,
+		secretToken:  secretToken,
+		pollTimeout:  30 * time.Second,
+	}
+}
+
+// Start consumes updates with getUpdates long polling
+func (b *Bot) Start(ctx context.Context) error {
+	if !b.running.CompareAndSwap(false, true) {
+		return errors.New("already running")
+	}
+
+	ctx, b.cancelFunc = context.WithCancel(ctx)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.poll(ctx)
+	}()
+
+	return nil
+}
+
+func (b *Bot) Stop(timeout time.Duration) {
+	if b.cancelFunc != nil {
+		b.cancelFunc()
+	}
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+		b.logger.Warn().Msg("telegram bot forced to stop due to timeout")
+	}
+}
+
+func (b *Bot) poll(ctx context.Context) {
+	var offset int64
+
+	for {
+		var updates []telegram.Update
+		err := b.client.Call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(b.pollTimeout.Seconds()),
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+
+		if ctx.Err() != nil {
+			b.logger.Info().Msg("telegram bot shutting down")
+			return
+		}
+
+		if err != nil {
+			b.logger.Error().Err(err).Msg("error getting telegram updates")
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for i := range updates {
+			b.HandleUpdate(ctx, &updates[i])
+			offset = updates[i].UpdateID + 1
+		}
+	}
+}
+
+// ServeHTTP handles webhook deliveries from the Bot API
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The secret is required in webhook mode, so an empty one never authenticates
+	token := r.Header.Get(secretTokenHeader)
+	if b.secretToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(b.secretToken)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var update telegram.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	b.HandleUpdate(r.Context(), &update)
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleUpdate processes a single update
+func (b *Bot) HandleUpdate(ctx context.Context, update *telegram.Update) {
+	if update.CallbackQuery != nil {
+		b.handleCallback(ctx, update.CallbackQuery)
+		return
+	}
+
+	if update.Message == nil {
+		return
+	}
+
+	command, args, _ := strings.Cut(strings.TrimSpace(update.Message.Text), " ")
+	if command == "/start" {
+		b.handleStart(ctx, update.Message, strings.TrimSpace(args))
+	}
+}
+
+// handleStart links the chat to the user who issued the code in the app
+func (b *Bot) handleStart(ctx context.Context, msg *telegram.Message, code string) {
+	logger := b.logger.With().Int64("chat_id", msg.Chat.ID).Logger()
+
+	if code == "" {
+		b.reply(ctx, msg.Chat.ID, "To receive alerts here, open Notification settings in the app and tap \"Connect Telegram\".")
+		return
+	}
+
+	// Channel posts have no sender to authorize alert actions with
+	if msg.From == nil {
+		b.reply(ctx, msg.Chat.ID, "This link has to be opened from a Telegram account.")
+		return
+	}
+
+	err := b.alertService.LinkTelegramChat(ctx, code, msg.Chat.ID, msg.From.ID)
+	switch {
+	case err == nil:
+		logger.Info().Msg("telegram chat linked")
+		b.reply(ctx, msg.Chat.ID, "Your Telegram account is connected. Alerts will be delivered to this chat.")
+	case errors.Is(err, service.ErrLinkCodeExpired):
+		b.reply(ctx, msg.Chat.ID, "This link has expired. Please request a new one in the app.")
+	case errors.Is(err, service.ErrLinkCodeUsed), errors.Is(err, service.ErrInvalidLinkCode):
+		b.reply(ctx, msg.Chat.ID, "This link is not valid. Please request a new one in the app.")
+	default:
+		logger.Error().Err(err).Msg("error linking telegram chat")
+		b.reply(ctx, msg.Chat.ID, "Something went wrong while connecting your account. Please try again.")
+	}
+}
+
+// handleCallback applies an alert action from an inline button and replaces the buttons
+// on the original message with a confirmation
+func (b *Bot) handleCallback(ctx context.Context, query *telegram.CallbackQuery) {
+	logger := b.logger.With().Int64("user_id", query.From.ID).Str("data", query.Data).Logger()
+
+	if query.Message == nil {
+		b.answerCallback(ctx, query.ID, "This message is too old to act on.")
+		return
+	}
+
+	action, alertID, symbol, err := notifier.ParseAlertAction(query.Data)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, "Unknown action.")
+		return
+	}
+
+	err = b.alertService.ApplyTelegramAlertAction(ctx, query.From.ID, action, alertID)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrNotAlertOwner), errors.Is(err, service.ErrAlertNotFound):
+		b.answerCallback(ctx, query.ID, "This alert is not linked to your Telegram account.")
+		return
+	default:
+		logger.Error().Err(err).Msg("error applying alert action")
+		b.answerCallback(ctx, query.ID, "Something went wrong. Please try again.")
+		return
+	}
+
+	var confirmation string
+	switch action {
+	case notifier.AlertActionSnooze:
+		confirmation = "Alert snoozed for 1 hour."
+	case notifier.AlertActionDisable:
+		confirmation = "Alert disabled."
+	case notifier.AlertActionMute:
+		confirmation = "Alerts for " + symbol + " muted."
+	}
+
+	b.answerCallback(ctx, query.ID, confirmation)
+
+	// Omitting reply_markup removes the buttons so the action can't be repeated
+	err = b.client.Call(ctx, "editMessageText", map[string]interface{}{
+		"chat_id":    query.Message.Chat.ID,
+		"message_id": query.Message.MessageID,
+		"text":       query.Message.Text + "\n\n" + confirmation,
+	}, nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("error editing alert message")
+	}
+}
+
+func (b *Bot) answerCallback(ctx context.Context, queryID string, text string) {
+	err := b.client.Call(ctx, "answerCallbackQuery", map[string]interface{}{
+		"callback_query_id": queryID,
+		"text":              text,
+	}, nil)
+	if err != nil {
+		b.logger.Error().Err(err).Msg("error answering telegram callback query")
+	}
+}
+
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) {
+	err := b.client.Call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+	if err != nil {
+		b.logger.Error().Err(err).Int64("chat_id", chatID).Msg("error replying to telegram chat")
+	}
+}
@@ -0,0 +1,156 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+type Options struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probes through
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of concurrent calls allowed while half-open
+	HalfOpenProbes int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+// Breaker stops calls to a failing dependency for a while, then probes it with a limited
+// number of calls before closing again
+type Breaker struct {
+	opts     Options
+	onChange func(State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	probes   int
+	openedAt time.Time
+}
+
+// New creates a Breaker. onChange is optional and called on every state transition.
+func New(opts Options, onChange func(State)) *Breaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 1
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+
+	return &Breaker{
+		opts:     opts,
+		onChange: onChange,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be followed by
+// Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if time.Since(b.openedAt) < b.opts.OpenTimeout {
+			return ErrOpen
+		}
+		b.setState(StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.opts.HalfOpenProbes {
+			return ErrOpen
+		}
+		b.probes++
+	}
+
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.openedAt = time.Now()
+		b.setState(StateOpen)
+	}
+}
+
+// Cancel releases a call that ended without telling anything about the dependency's health
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// RetryAt returns when an open breaker lets the next probe through
+func (b *Breaker) RetryAt() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateOpen {
+		return time.Now()
+	}
+	return b.openedAt.Add(b.opts.OpenTimeout)
+}
+
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.probes = 0
+	if state == StateClosed {
+		b.failures = 0
+	}
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
@@ -0,0 +1,156 @@
+package delayqueue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const groupSeparator = "|"
+
+// Job is a payload that became due
+type Job struct {
+	Group   string
+	ID      string
+	Payload []byte
+
+	leaseUntil int64
+}
+
+// claimScript leases up to ARGV[2] jobs due by ARGV[1] until ARGV[3], so that only one
+// instance gets each job and a job whose claimer died becomes due again
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local jobs = {}
+for _, id in ipairs(ids) do
+	local payload = redis.call('HGET', KEYS[2], id)
+	if payload then
+		redis.call('ZADD', KEYS[1], ARGV[3], id)
+		table.insert(jobs, id)
+		table.insert(jobs, payload)
+	else
+		redis.call('ZREM', KEYS[1], id)
+	end
+end
+return jobs
+`)
+
+// ackScript removes a leased job, unless it was rescheduled or claimed again meanwhile
+var ackScript = redis.NewScript(`
+if tonumber(redis.call('ZSCORE', KEYS[1], ARGV[1])) ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('SREM', KEYS[3], ARGV[1])
+return 1
+`)
+
+// DelayQueue schedules jobs in a Redis sorted set scored by their due time. Jobs belong
+// to a group so that all pending jobs of, for example, one alert can be cancelled at once.
+type DelayQueue struct {
+	client *redis.Client
+	key    string
+}
+
+func NewDelayQueue(client *redis.Client, key string) *DelayQueue {
+	return &DelayQueue{
+		client: client,
+		key:    key,
+	}
+}
+
+// Schedule adds a job to group, replacing any job with the same id
+func (q *DelayQueue) Schedule(ctx context.Context, group string, id string, payload []byte, at time.Time) error {
+	member := group + groupSeparator + id
+
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, q.payloadKey(), member, payload)
+	pipe.ZAdd(ctx, q.key, redis.Z{Score: float64(at.UnixMilli()), Member: member})
+	pipe.SAdd(ctx, q.groupKey(group), member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+	return nil
+}
+
+// Cancel removes the pending jobs of group whose id matches, or all of them when match
+// is nil, and returns how many were still scheduled
+func (q *DelayQueue) Cancel(ctx context.Context, group string, match func(id string) bool) (int64, error) {
+	members, err := q.client.SMembers(ctx, q.groupKey(group)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var cancelled []string
+	for _, member := range members {
+		_, id, _ := strings.Cut(member, groupSeparator)
+		if match == nil || match(id) {
+			cancelled = append(cancelled, member)
+		}
+	}
+	if len(cancelled) == 0 {
+		return 0, nil
+	}
+
+	zMembers := make([]interface{}, len(cancelled))
+	for i, member := range cancelled {
+		zMembers[i] = member
+	}
+
+	pipe := q.client.TxPipeline()
+	removed := pipe.ZRem(ctx, q.key, zMembers...)
+	pipe.HDel(ctx, q.payloadKey(), cancelled...)
+	pipe.SRem(ctx, q.groupKey(group), zMembers...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+	return removed.Val(), nil
+}
+
+// Claim leases up to limit jobs that are due by now. A job stays scheduled until it is
+// acknowledged and becomes due again once lease has passed.
+func (q *DelayQueue) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Job, error) {
+	leaseUntil := now.Add(lease).UnixMilli()
+
+	res, err := claimScript.Run(ctx, q.client,
+		[]string{q.key, q.payloadKey()},
+		strconv.FormatInt(now.UnixMilli(), 10), limit, strconv.FormatInt(leaseUntil, 10),
+	).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+
+	jobs := make([]Job, 0, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		group, id, _ := strings.Cut(res[i], groupSeparator)
+		jobs = append(jobs, Job{Group: group, ID: id, Payload: []byte(res[i+1]), leaseUntil: leaseUntil})
+	}
+	return jobs, nil
+}
+
+// Ack removes a claimed job once it was processed
+func (q *DelayQueue) Ack(ctx context.Context, job *Job) error {
+	member := job.Group + groupSeparator + job.ID
+
+	err := ackScript.Run(ctx, q.client,
+		[]string{q.key, q.payloadKey(), q.groupKey(job.Group)},
+		member, strconv.FormatInt(job.leaseUntil, 10),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to ack job: %w", err)
+	}
+	return nil
+}
+
+func (q *DelayQueue) payloadKey() string {
+	return q.key + ":payloads"
+}
+
+func (q *DelayQueue) groupKey(group string) string {
+	return q.key + ":group:" + group
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type EventHandlerMetrics struct {
+	EventsHandled   *prometheus.CounterVec
+	HandlerDuration *prometheus.HistogramVec
+	UnknownEvents   *prometheus.CounterVec
+}
+
+func InitEventHandlerMetrics() *EventHandlerMetrics {
+	return &EventHandlerMetrics{
+		EventsHandled: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "event_handler_events_total",
+				Help: "Events handled by type and outcome",
+			},
+			[]string{"event_type", "status"},
+		),
+
+		HandlerDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "event_handler_duration_seconds",
+				Help:    "Time taken by the handler of an event type, retries included",
+				Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"event_type"},
+		),
+
+		UnknownEvents: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "event_handler_unknown_events_total",
+				Help: "Events without a registered handler",
+			},
+			[]string{"event_type"},
+		),
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type NotificationMetrics struct {
+	// Delivery metrics
+	NotificationDeliveries      *prometheus.CounterVec
+	NotificationSendDuration    *prometheus.HistogramVec
+	NotificationDeliveryLatency *prometheus.HistogramVec
+
+	// Provider metrics
+	ProviderCircuitState *prometheus.GaugeVec
+	ProviderFailovers    *prometheus.CounterVec
+
+	// Rate limiting metrics
+	NotificationsRateLimited *prometheus.CounterVec
+
+	// Template metrics
+	InvalidTemplatesSkipped *prometheus.CounterVec
+}
+
+func InitNotificationMetrics() *NotificationMetrics {
+	return &NotificationMetrics{
+		NotificationDeliveries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notification_deliveries_total",
+				Help: "Total number of notification delivery attempts by outcome",
+			},
+			[]string{"channel", "status"},
+		),
+
+		NotificationSendDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "notification_send_duration_seconds",
+				Help:    "Time taken by the provider to accept a notification",
+				Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"channel"},
+		),
+
+		NotificationDeliveryLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "notification_delivery_latency_seconds",
+				Help:    "Time from queuing a notification to its successful delivery",
+				Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+			},
+			[]string{"channel"},
+		),
+
+		ProviderCircuitState: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notification_provider_circuit_state",
+				Help: "Circuit breaker state per provider (0=closed, 1=open, 2=half-open)",
+			},
+			[]string{"channel", "provider"},
+		),
+
+		ProviderFailovers: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notification_provider_failovers_total",
+				Help: "Total number of sends routed to a secondary provider",
+			},
+			[]string{"channel", "provider"},
+		),
+
+		NotificationsRateLimited: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_rate_limited_total",
+				Help: "Total number of notifications held back by the per-user rate limiter",
+			},
+			[]string{"channel", "action"},
+		),
+
+		InvalidTemplatesSkipped: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notification_invalid_templates_skipped_total",
+				Help: "Total number of renders that skipped an invalid stored template for a fallback",
+			},
+			[]string{"channel", "locale"},
+		),
+	}
+}
@@ -10,6 +10,17 @@ type WorkerMetrics struct {
 	EventProcessingDuration *prometheus.HistogramVec
 	EventsProcessedTotal    *prometheus.CounterVec
 	EventProcessingErrors   *prometheus.CounterVec
+	EventsDeadLettered      *prometheus.CounterVec
+	BatchSize               *prometheus.HistogramVec
+	BatchDuration           *prometheus.HistogramVec
+
+	// Pool metrics
+	WorkerPoolSize *prometheus.GaugeVec
+	WorkerScalings *prometheus.CounterVec
+
+	// Lane metrics
+	WorkerLanes *prometheus.GaugeVec
+	LaneBacklog *prometheus.GaugeVec
 
 	// Queue metrics
 	QueueSize       *prometheus.GaugeVec
@@ -53,6 +64,64 @@ func InitWorkerMetrics() *WorkerMetrics {
 			[]string{"queue", "worker_id", "event_type", "error_type"},
 		),
 
+		EventsDeadLettered: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_events_dead_lettered_total",
+				Help: "Total number of events moved to the dead-letter queue",
+			},
+			[]string{"queue", "event_type", "error_type"},
+		),
+
+		BatchSize: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "worker_batch_size",
+				Help:    "Number of events handed to the batch handler at once",
+				Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500},
+			},
+			[]string{"queue"},
+		),
+
+		BatchDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "worker_batch_duration_seconds",
+				Help:    "Time taken by the batch handler for a whole batch",
+				Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			},
+			[]string{"queue"},
+		),
+
+		WorkerPoolSize: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "worker_pool_size",
+				Help: "Number of running workers",
+			},
+			[]string{"queue"},
+		),
+
+		WorkerScalings: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_scaling_decisions_total",
+				Help: "Worker pool resizes by direction and the signal that caused them",
+			},
+			[]string{"queue", "direction", "reason"},
+		),
+
+		WorkerLanes: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "worker_lanes",
+				Help: "Number of ordered processing lanes",
+			},
+			[]string{"queue"},
+		),
+
+		LaneBacklog: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "worker_lane_backlog",
+				Help: "Events received and waiting in a processing lane; a full lane blocks its receiver",
+			},
+			[]string{"queue", "lane"},
+		),
+
 		QueueSize: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "worker_queue_size",
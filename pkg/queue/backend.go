@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendList   = "list"
+	BackendStream = "stream"
+)
+
+// ErrNoMessage is returned by Receive when nothing arrived within its blocking timeout
+var ErrNoMessage = errors.New("no message available")
+
+// Message is a payload received from a Backend
+type Message struct {
+	// ID identifies the message to the backend, such as a stream entry ID
+	ID       string
+	Payload  []byte
+	Attempts int
+
+	// item is the list item as stored, envelope included
+	item []byte
+}
+
+// Backend is the transport under Queue and worker.Worker. Consumers are numbered so that
+// backends can keep per-consumer state such as processing lists.
+type Backend interface {
+	Publish(ctx context.Context, payload []byte) error
+	Receive(ctx context.Context, consumer int) (*Message, error)
+	Ack(ctx context.Context, consumer int, msg *Message) error
+	// Nack hands a failed message back for redelivery and reports false when the
+	// backend cannot redeliver it
+	Nack(ctx context.Context, consumer int, msg *Message) (bool, error)
+	Depth(ctx context.Context) (int64, error)
+}
+
+// BatchBackend is implemented by backends that receive and acknowledge several messages
+// per round trip
+type BatchBackend interface {
+	Backend
+	// ReceiveBatch blocks until at least one message arrived and returns up to max
+	// messages without waiting for more
+	ReceiveBatch(ctx context.Context, consumer int, max int) ([]*Message, error)
+	AckBatch(ctx context.Context, consumer int, msgs []*Message) error
+}
+
+// Starter is implemented by backends that run background loops, such as reapers
+type Starter interface {
+	Start(ctx context.Context)
+}
+
+// Options configures the Redis backends
+type Options struct {
+	// Reliable makes the list backend keep messages in a per-consumer processing list
+	// until acknowledged. Streams are always reliable.
+	Reliable bool
+	// InstanceID names this process in processing list keys and stream consumer names;
+	// defaults to hostname and pid
+	InstanceID string
+	// VisibilityTimeout is how long a message may stay unacknowledged by a dead consumer
+	// before it is delivered again
+	VisibilityTimeout time.Duration
+	// ConsumerGroup is the stream consumer group, shared by all instances
+	ConsumerGroup string
+	// MaxLen caps the stream length on publish; 0 means uncapped
+	MaxLen int64
+	// MaxAge trims stream entries older than this on publish when MaxLen is 0; 0 keeps
+	// them. Trimming also drops entries still pending, so either bound has to leave room
+	// for every redelivery.
+	MaxAge time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.InstanceID == "" {
+		o.InstanceID = DefaultInstanceID()
+	}
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = 2 * time.Minute
+	}
+	if o.ConsumerGroup == "" {
+		o.ConsumerGroup = "workers"
+	}
+	return o
+}
+
+// NewRedisBackend returns the Redis backend of the given kind, BackendList or BackendStream
+func NewRedisBackend(client *redis.Client, key string, kind string, opts Options) (Backend, error) {
+	switch kind {
+	case BackendList, "":
+		return NewListBackend(client, key, opts), nil
+	case BackendStream:
+		return NewStreamBackend(client, key, opts), nil
+	}
+	return nil, fmt.Errorf("unknown queue backend %q", kind)
+}
+
+func DefaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
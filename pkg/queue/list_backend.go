@@ -0,0 +1,365 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// requeueOrphansScript moves everything left in the processing list KEYS[1] back to the
+// queue KEYS[3], unless its instance is still alive according to the heartbeat KEYS[2].
+// ARGV[1] set to 1 skips the heartbeat check, for the lists of this instance itself.
+// Items go to the consuming end of the queue so they are picked up next.
+var requeueOrphansScript = redis.NewScript(`
+if ARGV[1] ~= '1' and redis.call('EXISTS', KEYS[2]) == 1 then
+	return -1
+end
+local moved = 0
+while redis.call('LMOVE', KEYS[1], KEYS[3], 'LEFT', 'RIGHT') do
+	moved = moved + 1
+end
+redis.call('SREM', KEYS[4], KEYS[1])
+return moved
+`)
+
+// envelopePrefix starts every list item published by ListBackend, followed by a UUID
+// naming the message, a colon and the payload. The ID keys the delivery count, so that
+// identical payloads are counted apart.
+const envelopePrefix = "msg:"
+
+func wrapPayload(payload []byte) []byte {
+	item := make([]byte, 0, len(envelopePrefix)+37+len(payload))
+	item = append(item, envelopePrefix...)
+	item = append(item, uuid.NewString()...)
+	item = append(item, ':')
+	return append(item, payload...)
+}
+
+// unwrapPayload returns the message of a list item. Items pushed by other producers
+// without an envelope are named by their hash, so identical ones share a delivery count.
+func unwrapPayload(item []byte) *Message {
+	const idLen = 36
+	if rest, ok := bytes.CutPrefix(item, []byte(envelopePrefix)); ok && len(rest) > idLen && rest[idLen] == ':' {
+		return &Message{ID: string(rest[:idLen]), Payload: rest[idLen+1:], item: item}
+	}
+
+	sum := sha1.Sum(item)
+	return &Message{ID: hex.EncodeToString(sum[:]), Payload: item, item: item}
+}
+
+// ListBackend is a Redis list, published with LPUSH and consumed from the other end. In
+// reliable mode every message is moved to a per-consumer processing list while it is
+// handled, so that messages of a crashed or stopped instance are re-queued by the reaper
+// of another instance instead of being lost.
+type ListBackend struct {
+	client *redis.Client
+	key    string
+	opts   Options
+
+	// registered holds the processing lists of this instance, added to the set of
+	// processing lists again on every heartbeat
+	registered sync.Map
+}
+
+func NewListBackend(client *redis.Client, key string, opts Options) *ListBackend {
+	return &ListBackend{
+		client: client,
+		key:    key,
+		opts:   opts.withDefaults(),
+	}
+}
+
+func (b *ListBackend) processingListKey(consumer int) string {
+	return fmt.Sprintf("%s:processing:%s:%d", b.key, b.opts.InstanceID, consumer)
+}
+
+// processingListsKey is the set of every processing list of the queue, across instances
+func (b *ListBackend) processingListsKey() string {
+	return b.key + ":processing-lists"
+}
+
+func (b *ListBackend) heartbeatKey(instanceID string) string {
+	return b.key + ":heartbeat:" + instanceID
+}
+
+func (b *ListBackend) deliveriesKey() string {
+	return b.key + ":deliveries"
+}
+
+// instanceOf extracts the instance ID from a processing list key
+func (b *ListBackend) instanceOf(processingList string) string {
+	instance := strings.TrimPrefix(processingList, b.key+":processing:")
+	if i := strings.LastIndex(instance, ":"); i >= 0 {
+		instance = instance[:i]
+	}
+	return instance
+}
+
+func (b *ListBackend) Publish(ctx context.Context, payload []byte) error {
+	return b.client.LPush(ctx, b.key, wrapPayload(payload)).Err()
+}
+
+// Start runs the heartbeat of this instance and the reaper that re-queues the processing
+// lists of instances whose heartbeat expired. Lists left by an earlier run under the same
+// instance ID, such as a restarted container keeping its hostname and pid, are re-queued
+// first, as the reaper skips them while this instance is alive.
+func (b *ListBackend) Start(ctx context.Context) {
+	if !b.opts.Reliable {
+		return
+	}
+
+	if err := b.requeueLists(ctx, true); err != nil {
+		log.Error().Err(err).Str("queue", b.key).Msg("error re-queueing messages of a previous run")
+	}
+
+	go b.heartbeat(ctx)
+	go b.reap(ctx)
+}
+
+func (b *ListBackend) Receive(ctx context.Context, consumer int) (*Message, error) {
+	// Create a separate context with timeout for the blocking pop
+	popCtx, popCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer popCancel()
+
+	if !b.opts.Reliable {
+		result, err := b.client.BRPop(popCtx, 5*time.Second, b.key).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNoMessage
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg := unwrapPayload([]byte(result[1]))
+		msg.Attempts = 1
+		return msg, nil
+	}
+
+	processingList := b.processingListKey(consumer)
+	if _, ok := b.registered.Load(processingList); !ok {
+		if err := b.client.SAdd(ctx, b.processingListsKey(), processingList).Err(); err != nil {
+			return nil, fmt.Errorf("failed to register processing list: %w", err)
+		}
+		b.registered.Store(processingList, struct{}{})
+	}
+
+	item, err := b.client.BLMove(popCtx, b.key, processingList, "RIGHT", "LEFT", 5*time.Second).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoMessage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg := unwrapPayload(item)
+	attempts, err := b.client.HIncrBy(ctx, b.deliveriesKey(), msg.ID, 1).Result()
+	if err != nil {
+		log.Warn().Err(err).Str("queue", b.key).Msg("error counting message delivery")
+	}
+	msg.Attempts = int(attempts)
+
+	return msg, nil
+}
+
+func (b *ListBackend) ReceiveBatch(ctx context.Context, consumer int, max int) ([]*Message, error) {
+	if !b.opts.Reliable {
+		popCtx, popCancel := context.WithTimeout(ctx, 10*time.Second)
+		defer popCancel()
+
+		_, items, err := b.client.BLMPop(popCtx, 5*time.Second, "right", int64(max), b.key).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNoMessage
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		msgs := make([]*Message, len(items))
+		for i, item := range items {
+			msgs[i] = unwrapPayload([]byte(item))
+			msgs[i].Attempts = 1
+		}
+		return msgs, nil
+	}
+
+	// Block for the first message only, then take whatever else is queued. LMPOP cannot
+	// move to another list, so the rest are moved one by one in a single round trip.
+	first, err := b.Receive(ctx, consumer)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []*Message{first}
+	if max <= 1 {
+		return msgs, nil
+	}
+
+	processingList := b.processingListKey(consumer)
+	pipe := b.client.Pipeline()
+	moves := make([]*redis.StringCmd, max-1)
+	for i := range moves {
+		moves[i] = pipe.LMove(ctx, b.key, processingList, "RIGHT", "LEFT")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		// The first message is already in the processing list and is handled alone
+		log.Warn().Err(err).Str("queue", b.key).Msg("error receiving message batch")
+		return msgs, nil
+	}
+
+	for _, move := range moves {
+		item, err := move.Bytes()
+		if err != nil {
+			break
+		}
+		msgs = append(msgs, unwrapPayload(item))
+	}
+
+	if len(msgs) > 1 {
+		pipe = b.client.Pipeline()
+		counts := make([]*redis.IntCmd, len(msgs)-1)
+		for i, msg := range msgs[1:] {
+			counts[i] = pipe.HIncrBy(ctx, b.deliveriesKey(), msg.ID, 1)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Warn().Err(err).Str("queue", b.key).Msg("error counting message deliveries")
+		}
+		for i, count := range counts {
+			msgs[i+1].Attempts = int(count.Val())
+		}
+	}
+
+	return msgs, nil
+}
+
+func (b *ListBackend) Ack(ctx context.Context, consumer int, msg *Message) error {
+	if !b.opts.Reliable {
+		return nil
+	}
+
+	pipe := b.client.TxPipeline()
+	pipe.LRem(ctx, b.processingListKey(consumer), 1, msg.item)
+	pipe.HDel(ctx, b.deliveriesKey(), msg.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *ListBackend) AckBatch(ctx context.Context, consumer int, msgs []*Message) error {
+	if !b.opts.Reliable || len(msgs) == 0 {
+		return nil
+	}
+
+	processingList := b.processingListKey(consumer)
+	pipe := b.client.TxPipeline()
+	for _, msg := range msgs {
+		pipe.LRem(ctx, processingList, 1, msg.item)
+		pipe.HDel(ctx, b.deliveriesKey(), msg.ID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Nack puts a failed message back at the far end of the queue, keeping its envelope and
+// with it its delivery count
+func (b *ListBackend) Nack(ctx context.Context, consumer int, msg *Message) (bool, error) {
+	if !b.opts.Reliable {
+		return false, nil
+	}
+
+	pipe := b.client.TxPipeline()
+	pipe.LRem(ctx, b.processingListKey(consumer), 1, msg.item)
+	pipe.LPush(ctx, b.key, msg.item)
+	_, err := pipe.Exec(ctx)
+	return err == nil, err
+}
+
+func (b *ListBackend) Depth(ctx context.Context) (int64, error) {
+	return b.client.LLen(ctx, b.key).Result()
+}
+
+// heartbeat keeps this instance alive and its processing lists registered. A reaper that
+// saw the heartbeat expire, such as during a network partition, re-queued the lists and
+// dropped them from the set; adding them again keeps them visible to later reapers.
+func (b *ListBackend) heartbeat(ctx context.Context) {
+	key := b.heartbeatKey(b.opts.InstanceID)
+	ticker := time.NewTicker(b.opts.VisibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		var lists []interface{}
+		b.registered.Range(func(list, _ any) bool {
+			lists = append(lists, list)
+			return true
+		})
+
+		pipe := b.client.Pipeline()
+		pipe.Set(ctx, key, time.Now().Unix(), b.opts.VisibilityTimeout)
+		if len(lists) > 0 {
+			pipe.SAdd(ctx, b.processingListsKey(), lists...)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("queue", b.key).Msg("error refreshing worker heartbeat")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *ListBackend) reap(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.VisibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.requeueLists(ctx, false); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Str("queue", b.key).Msg("error re-queueing orphaned messages")
+			}
+		}
+	}
+}
+
+// requeueLists re-queues the processing lists of dead instances, or with own those of
+// this instance, which must only be done before it starts consuming
+func (b *ListBackend) requeueLists(ctx context.Context, own bool) error {
+	lists, err := b.client.SMembers(ctx, b.processingListsKey()).Result()
+	if err != nil {
+		return err
+	}
+
+	force := "0"
+	if own {
+		force = "1"
+	}
+
+	for _, list := range lists {
+		instance := b.instanceOf(list)
+		if (instance == b.opts.InstanceID) != own {
+			continue
+		}
+
+		keys := []string{list, b.heartbeatKey(instance), b.key, b.processingListsKey()}
+		moved, err := requeueOrphansScript.Run(ctx, b.client, keys, force).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to re-queue %s: %w", list, err)
+		}
+		if moved > 0 {
+			log.Warn().Str("processing_list", list).Int("messages", moved).Msg("re-queued messages of a dead worker")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnwrapPayloadNamesIdenticalPayloadsApart(t *testing.T) {
+	payload := []byte(`{"type":"alert.triggered"}`)
+
+	first := unwrapPayload(wrapPayload(payload))
+	second := unwrapPayload(wrapPayload(payload))
+
+	if !bytes.Equal(first.Payload, payload) || !bytes.Equal(second.Payload, payload) {
+		t.Fatalf("expected the published payload, got %q and %q", first.Payload, second.Payload)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("identical payloads share the ID %s", first.ID)
+	}
+}
+
+func TestUnwrapPayloadAcceptsItemsWithoutEnvelope(t *testing.T) {
+	item := []byte(`{"type":"alert.triggered"}`)
+
+	msg := unwrapPayload(item)
+	if !bytes.Equal(msg.Payload, item) || msg.ID == "" {
+		t.Fatalf("expected the item as payload with a hash ID, got %q with ID %q", msg.Payload, msg.ID)
+	}
+
+	// A payload that merely starts like an envelope is kept whole
+	item = []byte("msg:not-an-id")
+	if msg := unwrapPayload(item); !bytes.Equal(msg.Payload, item) {
+		t.Fatalf("expected the item as payload, got %q", msg.Payload)
+	}
+}
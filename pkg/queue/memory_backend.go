@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps messages in process memory. It is meant for tests and local runs:
+// messages do not survive a restart and are not shared between processes.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	messages []*Message
+	inFlight map[string]*Message
+	nextID   uint64
+	// published is closed and replaced on every publish to wake up waiting consumers
+	published chan struct{}
+
+	// pollTimeout is how long Receive blocks before returning ErrNoMessage
+	pollTimeout time.Duration
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		inFlight:    make(map[string]*Message),
+		published:   make(chan struct{}),
+		pollTimeout: 5 * time.Second,
+	}
+}
+
+func (b *MemoryBackend) Publish(ctx context.Context, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	b.push(&Message{ID: strconv.FormatUint(b.nextID, 10), Payload: payload})
+	return nil
+}
+
+// push appends a message and wakes up waiting consumers; b.mu must be held
+func (b *MemoryBackend) push(msg *Message) {
+	b.messages = append(b.messages, msg)
+	close(b.published)
+	b.published = make(chan struct{})
+}
+
+func (b *MemoryBackend) Receive(ctx context.Context, consumer int) (*Message, error) {
+	msgs, err := b.ReceiveBatch(ctx, consumer, 1)
+	if err != nil {
+		return nil, err
+	}
+	return msgs[0], nil
+}
+
+func (b *MemoryBackend) ReceiveBatch(ctx context.Context, consumer int, max int) ([]*Message, error) {
+	timeout := time.NewTimer(b.pollTimeout)
+	defer timeout.Stop()
+
+	for {
+		b.mu.Lock()
+		if len(b.messages) > 0 {
+			n := min(max, len(b.messages))
+			msgs := make([]*Message, n)
+			for i, msg := range b.messages[:n] {
+				msg.Attempts++
+				b.inFlight[msg.ID] = msg
+				received := *msg
+				msgs[i] = &received
+				b.messages[i] = nil
+			}
+			b.messages = b.messages[n:]
+			b.mu.Unlock()
+
+			return msgs, nil
+		}
+		published := b.published
+		b.mu.Unlock()
+
+		select {
+		case <-published:
+		case <-timeout.C:
+			return nil, ErrNoMessage
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (b *MemoryBackend) Ack(ctx context.Context, consumer int, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.inFlight, msg.ID)
+	return nil
+}
+
+func (b *MemoryBackend) AckBatch(ctx context.Context, consumer int, msgs []*Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, msg := range msgs {
+		delete(b.inFlight, msg.ID)
+	}
+	return nil
+}
+
+// Nack puts the message back at the end of the queue
+func (b *MemoryBackend) Nack(ctx context.Context, consumer int, msg *Message) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	inFlight, ok := b.inFlight[msg.ID]
+	if !ok {
+		return false, nil
+	}
+	delete(b.inFlight, msg.ID)
+	b.push(inFlight)
+	return true, nil
+}
+
+func (b *MemoryBackend) Depth(ctx context.Context) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return int64(len(b.messages)), nil
+}
@@ -5,21 +5,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
+
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 )
 
 // Queue implementation with zerolog
 type Queue struct {
-	client *redis.Client
-	key    string
+	backend Backend
+	key     string
 }
 
-// NewQueue creates a new Queue instance
+// NewQueue creates a new Queue instance on a Redis list
 func NewQueue(client *redis.Client, key string) *Queue {
+	return NewQueueWithBackend(NewListBackend(client, key, Options{}), key)
+}
+
+// NewQueueWithBackend creates a new Queue instance publishing to backend; key only names
+// the queue in logs
+func NewQueueWithBackend(backend Backend, key string) *Queue {
 	return &Queue{
-		client: client,
-		key:    key,
+		backend: backend,
+		key:     key,
 	}
 }
 
@@ -28,16 +36,19 @@ func (q *Queue) Publish(ctx context.Context, event interface{}) error {
 	return q.publishEvent(ctx, event)
 }
 
-// publishEvent handles the actual serialization and publishing to Redis
+// publishEvent handles the actual serialization and publishing to the backend
 func (q *Queue) publishEvent(ctx context.Context, event interface{}) error {
+	if e, ok := event.(*events.Event); ok && e.EnqueuedAt.IsZero() {
+		e.EnqueuedAt = time.Now()
+	}
+
 	// Marshal the event to JSON
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	// Push to Redis
-	err = q.client.LPush(ctx, q.key, data).Err()
+	err = q.backend.Publish(ctx, data)
 	if err != nil {
 		return fmt.Errorf("failed to push event: %w", err)
 	}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Stream publishes events to a Redis Stream capped at roughly maxLen entries
+type Stream struct {
+	backend *StreamBackend
+	key     string
+}
+
+// NewStream creates a new Stream instance
+func NewStream(client *redis.Client, key string, maxLen int64) *Stream {
+	return &Stream{
+		backend: NewStreamBackend(client, key, Options{MaxLen: maxLen}),
+		key:     key,
+	}
+}
+
+// Publish appends an event to the stream and returns its entry ID
+func (s *Stream) Publish(ctx context.Context, event interface{}) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	eventType := getEventType(event)
+
+	id, err := s.backend.add(ctx, eventType, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to add event to stream: %w", err)
+	}
+
+	log.Debug().
+		Str("stream", s.key).
+		Str("event_type", eventType).
+		Str("entry_id", id).
+		Int("data_size", len(data)).
+		Msg("event published to stream")
+
+	return id, nil
+}
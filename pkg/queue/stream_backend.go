@@ -0,0 +1,294 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamBackend is a Redis Stream consumed through a consumer group. Entries stay pending
+// until acknowledged; entries left pending longer than the visibility timeout by a
+// crashed consumer are claimed again with XAUTOCLAIM. Failed entries are added again.
+type StreamBackend struct {
+	client *redis.Client
+	key    string
+	opts   Options
+
+	// claimed holds entries taken over from idle consumers until a consumer picks them up
+	claimed chan *Message
+}
+
+func NewStreamBackend(client *redis.Client, key string, opts Options) *StreamBackend {
+	return &StreamBackend{
+		client:  client,
+		key:     key,
+		opts:    opts.withDefaults(),
+		claimed: make(chan *Message, 100),
+	}
+}
+
+func (b *StreamBackend) consumerName(consumer int) string {
+	return fmt.Sprintf("%s-%d", b.opts.InstanceID, consumer)
+}
+
+// Publish adds the payload to the stream, along with its event type for consumers that
+// only look at the type
+func (b *StreamBackend) Publish(ctx context.Context, payload []byte) error {
+	_, err := b.add(ctx, payloadType(payload), payload)
+	return err
+}
+
+// add appends an entry and trims the stream, as acknowledged entries are never deleted
+func (b *StreamBackend) add(ctx context.Context, eventType string, payload []byte) (string, error) {
+	return b.client.XAdd(ctx, b.entry(eventType, payload, 0)).Result()
+}
+
+// entry builds the XADD of a payload. attempts carries the deliveries of the entry a
+// nacked payload was added from.
+func (b *StreamBackend) entry(eventType string, payload []byte, attempts int) *redis.XAddArgs {
+	values := map[string]interface{}{
+		"type": eventType,
+		"data": payload,
+	}
+	if attempts > 0 {
+		values["attempts"] = attempts
+	}
+	args := &redis.XAddArgs{
+		Stream: b.key,
+		Values: values,
+	}
+
+	// Approximate trimming lets Redis drop whole nodes, which is much cheaper
+	switch {
+	case b.opts.MaxLen > 0:
+		args.MaxLen = b.opts.MaxLen
+		args.Approx = true
+	case b.opts.MaxAge > 0:
+		args.MinID = strconv.FormatInt(time.Now().Add(-b.opts.MaxAge).UnixMilli(), 10)
+		args.Approx = true
+	}
+
+	return args
+}
+
+func payloadType(payload []byte) string {
+	var event struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(payload, &event)
+	return event.Type
+}
+
+// Start creates the consumer group and runs the claiming of idle entries
+func (b *StreamBackend) Start(ctx context.Context) {
+	if err := b.createGroup(ctx); err != nil {
+		log.Error().Err(err).Str("stream", b.key).Str("group", b.opts.ConsumerGroup).Msg("error creating consumer group")
+	}
+
+	go b.autoclaim(ctx)
+}
+
+func (b *StreamBackend) createGroup(ctx context.Context) error {
+	// Starting from 0 delivers entries added before the group existed
+	err := b.client.XGroupCreateMkStream(ctx, b.key, b.opts.ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (b *StreamBackend) Receive(ctx context.Context, consumer int) (*Message, error) {
+	msgs, err := b.ReceiveBatch(ctx, consumer, 1)
+	if err != nil {
+		return nil, err
+	}
+	return msgs[0], nil
+}
+
+// ReceiveBatch returns claimed entries first, and reads new entries only when none are
+// waiting
+func (b *StreamBackend) ReceiveBatch(ctx context.Context, consumer int, max int) ([]*Message, error) {
+	var msgs []*Message
+	for len(msgs) < max {
+		select {
+		case msg := <-b.claimed:
+			msgs = append(msgs, msg)
+			continue
+		default:
+		}
+		break
+	}
+	if len(msgs) > 0 {
+		return msgs, nil
+	}
+
+	readCtx, readCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer readCancel()
+
+	streams, err := b.client.XReadGroup(readCtx, &redis.XReadGroupArgs{
+		Group:    b.opts.ConsumerGroup,
+		Consumer: b.consumerName(consumer),
+		Streams:  []string{b.key, ">"},
+		Count:    int64(max),
+		Block:    5 * time.Second,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoMessage
+	}
+	if err != nil {
+		// The stream was deleted along with its groups
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			if createErr := b.createGroup(ctx); createErr != nil {
+				return nil, errors.Join(err, createErr)
+			}
+		}
+		return nil, err
+	}
+
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			msgs = append(msgs, streamMessage(message, 1))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil, ErrNoMessage
+	}
+
+	return msgs, nil
+}
+
+func (b *StreamBackend) Ack(ctx context.Context, consumer int, msg *Message) error {
+	return b.client.XAck(ctx, b.key, b.opts.ConsumerGroup, msg.ID).Err()
+}
+
+func (b *StreamBackend) AckBatch(ctx context.Context, consumer int, msgs []*Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return b.client.XAck(ctx, b.key, b.opts.ConsumerGroup, ids...).Err()
+}
+
+// Nack adds the payload again as a new entry carrying its attempts and acknowledges the
+// failed one, so that it is delivered again right away rather than claimed after the
+// visibility timeout
+func (b *StreamBackend) Nack(ctx context.Context, consumer int, msg *Message) (bool, error) {
+	pipe := b.client.TxPipeline()
+	pipe.XAdd(ctx, b.entry(payloadType(msg.Payload), msg.Payload, msg.Attempts))
+	pipe.XAck(ctx, b.key, b.opts.ConsumerGroup, msg.ID)
+	_, err := pipe.Exec(ctx)
+	return err == nil, err
+}
+
+// Depth is the number of entries not yet delivered to the group plus those pending
+func (b *StreamBackend) Depth(ctx context.Context) (int64, error) {
+	groups, err := b.client.XInfoGroups(ctx, b.key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, group := range groups {
+		if group.Name == b.opts.ConsumerGroup {
+			return group.Lag + group.Pending, nil
+		}
+	}
+
+	return b.client.XLen(ctx, b.key).Result()
+}
+
+func (b *StreamBackend) autoclaim(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.VisibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.claimIdle(ctx); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Str("stream", b.key).Msg("error claiming idle stream entries")
+			}
+		}
+	}
+}
+
+// claimIdle takes over entries idle for longer than the visibility timeout and queues
+// them for the consumers of this instance. Entries still waiting in the queue would turn
+// idle and be claimed again, so nothing is claimed until the consumers took them all, and
+// never more than the queue holds.
+func (b *StreamBackend) claimIdle(ctx context.Context) error {
+	if len(b.claimed) > 0 {
+		return nil
+	}
+
+	consumer := b.opts.InstanceID + "-claimer"
+	start := "0-0"
+	free := cap(b.claimed)
+
+	for free > 0 {
+		messages, next, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   b.key,
+			Group:    b.opts.ConsumerGroup,
+			Consumer: consumer,
+			MinIdle:  b.opts.VisibilityTimeout,
+			Start:    start,
+			Count:    int64(free),
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			// Entries trimmed while pending come back without their fields
+			if _, ok := message.Values["data"]; !ok {
+				if err := b.client.XAck(ctx, b.key, b.opts.ConsumerGroup, message.ID).Err(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			attempts := 1
+			pending, err := b.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: b.key,
+				Group:  b.opts.ConsumerGroup,
+				Start:  message.ID,
+				End:    message.ID,
+				Count:  1,
+			}).Result()
+			if err == nil && len(pending) == 1 {
+				attempts = int(pending[0].RetryCount)
+			}
+
+			// This is the only sender and it claimed no more than there is room for
+			b.claimed <- streamMessage(message, attempts)
+			free--
+		}
+
+		if next == "0-0" || next == "" || len(messages) == 0 {
+			return nil
+		}
+		start = next
+	}
+
+	return nil
+}
+
+// streamMessage reads the payload from the data field written by Publish. deliveries
+// counts those of this entry, on top of the attempts of the entries it was nacked from.
+func streamMessage(message redis.XMessage, deliveries int) *Message {
+	payload, _ := message.Values["data"].(string)
+	attempts, _ := message.Values["attempts"].(string)
+	previous, _ := strconv.Atoi(attempts)
+	return &Message{ID: message.ID, Payload: []byte(payload), Attempts: previous + deliveries}
+}
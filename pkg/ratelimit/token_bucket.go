@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit describes a token bucket refilled at Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute builds a Limit from a per-minute rate
+func PerMinute(perMinute float64, burst int) Limit {
+	return Limit{Rate: perMinute / 60, Burst: burst}
+}
+
+// tokenBucketScript refills the bucket based on elapsed time and takes a single token.
+// It returns {allowed, retry_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+// TokenBucket is a Redis-backed token bucket limiter shared by all worker instances
+type TokenBucket struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewTokenBucket(client *redis.Client, prefix string) *TokenBucket {
+	return &TokenBucket{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Allow takes a token for key, returning false and the time until the next token when the bucket is empty
+func (b *TokenBucket) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return true, 0, nil
+	}
+
+	res, err := tokenBucketScript.Run(ctx, b.client,
+		[]string{b.prefix + ":" + key},
+		limit.Rate, limit.Burst, time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run token bucket: %w", err)
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Client is a minimal Telegram Bot API client
+type Client struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+func NewClient(token string, baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		token:   token,
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Error is returned when the Bot API rejects a request
+type Error struct {
+	Method      string
+	StatusCode  int
+	Description string
+	RetryAfter  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram %s failed with status %d: %s", e.Method, e.StatusCode, e.Description)
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Call invokes a Bot API method and decodes its result into result when it is not nil
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var res response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("failed to decode telegram response: %w", err)
+	}
+	if !res.OK {
+		apiErr := &Error{Method: method, StatusCode: resp.StatusCode, Description: res.Description}
+		if res.Parameters != nil {
+			apiErr.RetryAfter = res.Parameters.RetryAfter
+		}
+		return apiErr
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("failed to decode telegram %s result: %w", method, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrSubscriptionGone is returned when the push service reports the subscription no
+// longer exists and it should not be used again
+var ErrSubscriptionGone = errors.New("web push subscription is gone")
+
+// StatusError is returned when the push service answers with an unsuccessful status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("web push request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Subscription is a browser PushSubscription as returned by PushManager.subscribe()
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+type Urgency string
+
+const (
+	UrgencyNormal Urgency = "normal"
+	UrgencyHigh   Urgency = "high"
+)
+
+type Client struct {
+	vapid  *VAPID
+	client *http.Client
+}
+
+func NewClient(vapid *VAPID, timeout time.Duration) *Client {
+	return &Client{
+		vapid:  vapid,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send encrypts and delivers payload to the subscription's push service and returns the
+// message location it reports
+func (c *Client) Send(ctx context.Context, sub *Subscription, payload []byte, ttl time.Duration, urgency Urgency) (string, error) {
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return "", err
+	}
+
+	authorization, err := c.vapid.Authorization(sub.Endpoint, time.Now().Add(12*time.Hour))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create web push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Urgency", string(urgency))
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("web push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", ErrSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return resp.Header.Get("Location"), nil
+}
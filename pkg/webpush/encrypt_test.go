@@ -0,0 +1,63 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// The example of RFC 8291 section 5
+const (
+	rfcPlaintext    = "When I grow up, I want to be a watermelon"
+	rfcASPrivateKey = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPublicKey  = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcSalt         = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcAuthSecret   = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcMessage      = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func decodeTestKey(t *testing.T, key string) []byte {
+	t.Helper()
+
+	raw, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestEncryptMatchesRFC8291Example(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(decodeTestKey(t, rfcASPrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &Subscription{P256dh: rfcUAPublicKey, Auth: rfcAuthSecret}
+	got, err := encrypt(sub, []byte(rfcPlaintext), asPrivate, decodeTestKey(t, rfcSalt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := decodeTestKey(t, rfcMessage); !bytes.Equal(got, want) {
+		t.Fatalf("encrypted message mismatch\ngot  %s\nwant %s", base64.RawURLEncoding.EncodeToString(got), rfcMessage)
+	}
+}
+
+func TestEncryptAcceptsPaddedKeys(t *testing.T) {
+	sub := &Subscription{
+		P256dh: base64.URLEncoding.EncodeToString(decodeTestKey(t, rfcUAPublicKey)),
+		Auth:   base64.URLEncoding.EncodeToString(decodeTestKey(t, rfcAuthSecret)),
+	}
+	if _, err := Encrypt(sub, []byte(rfcPlaintext)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptRejectsOversizedPayloads(t *testing.T) {
+	sub := &Subscription{P256dh: rfcUAPublicKey, Auth: rfcAuthSecret}
+	if _, err := Encrypt(sub, make([]byte, maxPayloadSize+1)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AutoscaleOptions sizes the worker pool from the queue depth, the age of events when they
+// are handled and the handler latency
+type AutoscaleOptions struct {
+	MinWorkers int
+	MaxWorkers int
+	// Interval between scaling decisions
+	Interval time.Duration
+	// BacklogPerWorker is the queue depth per worker above which the pool grows
+	BacklogPerWorker int
+	// MaxEventAge and MaxLatency grow the pool when, while there is a backlog, events wait
+	// or handlers take longer than this on average
+	MaxEventAge time.Duration
+	MaxLatency  time.Duration
+	// ScaleUpCooldown and ScaleDownCooldown are how long after a resize the pool may next
+	// grow or shrink, to avoid flapping
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+}
+
+func (o AutoscaleOptions) withDefaults() AutoscaleOptions {
+	if o.MinWorkers <= 0 {
+		o.MinWorkers = 1
+	}
+	if o.MaxWorkers < o.MinWorkers {
+		o.MaxWorkers = o.MinWorkers
+	}
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	if o.BacklogPerWorker <= 0 {
+		o.BacklogPerWorker = 10
+	}
+	if o.MaxEventAge <= 0 {
+		o.MaxEventAge = 5 * time.Second
+	}
+	if o.MaxLatency <= 0 {
+		o.MaxLatency = time.Second
+	}
+	if o.ScaleUpCooldown <= 0 {
+		o.ScaleUpCooldown = 30 * time.Second
+	}
+	if o.ScaleDownCooldown <= 0 {
+		o.ScaleDownCooldown = 2 * time.Minute
+	}
+	return o
+}
+
+// target returns the pool size for the observed signals and the signal that decided it.
+// The pool grows by a quarter and shrinks by a tenth at a time.
+func (o *AutoscaleOptions) target(current int, depth int64, avgAge float64, avgLatency float64) (int, string) {
+	size, reason := current, ""
+
+	switch {
+	case depth > int64(current*o.BacklogPerWorker):
+		size, reason = current+max(1, current/4), "queue_depth"
+	case depth > 0 && avgAge > o.MaxEventAge.Seconds():
+		size, reason = current+max(1, current/4), "event_age"
+	case depth > 0 && avgLatency > o.MaxLatency.Seconds():
+		size, reason = current+max(1, current/4), "latency"
+	case depth == 0 && avgAge < o.MaxEventAge.Seconds()/2:
+		size, reason = current-max(1, current/10), "idle"
+	}
+
+	return min(max(size, o.MinWorkers), o.MaxWorkers), reason
+}
+
+// processingStats accumulates event age and handler latency between scaling decisions
+type processingStats struct {
+	mu         sync.Mutex
+	events     int
+	ageSum     float64
+	latencySum float64
+}
+
+func (s *processingStats) observe(age float64, latency float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events++
+	s.ageSum += age
+	s.latencySum += latency
+}
+
+// take returns the averages since the last call and resets them
+func (s *processingStats) take() (events int, avgAge float64, avgLatency float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events = s.events
+	if events > 0 {
+		avgAge = s.ageSum / float64(events)
+		avgLatency = s.latencySum / float64(events)
+	}
+	s.events, s.ageSum, s.latencySum = 0, 0, 0
+	return events, avgAge, avgLatency
+}
+
+func (w *Worker) startAutoscaling(ctx context.Context) {
+	opts := w.autoscale.withDefaults()
+	w.resize(ctx, opts.MinWorkers)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.runAutoscaler(ctx, &opts)
+	}()
+}
+
+func (w *Worker) runAutoscaler(ctx context.Context, opts *AutoscaleOptions) {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var lastResize time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !w.depthSampled.Load() {
+			continue
+		}
+		depth := w.depth.Load()
+
+		events, avgAge, avgLatency := w.stats.take()
+		current := len(w.pool)
+		size, reason := opts.target(current, depth, avgAge, avgLatency)
+		if size == current {
+			continue
+		}
+
+		direction, cooldown := "up", opts.ScaleUpCooldown
+		if size < current {
+			direction, cooldown = "down", opts.ScaleDownCooldown
+		}
+		if time.Since(lastResize) < cooldown {
+			continue
+		}
+
+		w.metrics.WorkerScalings.WithLabelValues(w.key, direction, reason).Inc()
+		w.logger.Info().
+			Str("queue", w.key).
+			Str("direction", direction).
+			Str("reason", reason).
+			Int("from", current).
+			Int("to", size).
+			Int64("queue_size", depth).
+			Int("events", events).
+			Float64("avg_event_age_seconds", avgAge).
+			Float64("avg_latency_seconds", avgLatency).
+			Msg("resizing worker pool")
+
+		w.resize(ctx, size)
+		lastResize = time.Now()
+	}
+}
+
+// resize starts or retires workers until size are running. Retired workers finish the
+// event they are handling first. Only the autoscaler calls it after start.
+func (w *Worker) resize(ctx context.Context, size int) {
+	for len(w.pool) < size {
+		stop := make(chan struct{})
+		w.startWorker(ctx, len(w.pool), stop)
+		w.pool = append(w.pool, stop)
+	}
+	for len(w.pool) > size {
+		last := len(w.pool) - 1
+		close(w.pool[last])
+		w.pool = w.pool[:last]
+	}
+
+	w.metrics.WorkerPoolSize.WithLabelValues(w.key).Set(float64(size))
+}
@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/queue"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// BatchHandler handles several events at once. It returns one error per event, in the
+// order of events, or nil when every event succeeded.
+type BatchHandler func(ctx context.Context, events []*events.Event) []error
+
+// processBatch is process for a BatchHandler: each iteration receives up to batchSize
+// events, hands the decodable ones to the handler and acknowledges the successful ones
+// together
+func (w *Worker) processBatch(ctx context.Context, workerID int, stop <-chan struct{}) error {
+	logger := w.logger.With().Int("worker_id", workerID).Logger()
+	workerIDStr := fmt.Sprintf("%d", workerID)
+
+	w.metrics.WorkerStatus.WithLabelValues(w.key, workerIDStr).Set(1)
+	defer w.metrics.WorkerStatus.WithLabelValues(w.key, workerIDStr).Set(0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("worker shutting down")
+			return nil
+		case <-stop:
+			logger.Info().Msg("worker retired by autoscaler")
+			return nil
+		default:
+			w.metrics.WorkerBusy.WithLabelValues(w.key, workerIDStr).Set(0)
+
+			msgs, err := w.receiveBatch(ctx, workerID)
+			if err != nil {
+				if w.receiveFailed(ctx, &logger, workerIDStr, err) {
+					return nil
+				}
+				continue
+			}
+
+			w.metrics.WorkerBusy.WithLabelValues(w.key, workerIDStr).Set(1)
+			w.metrics.BatchSize.WithLabelValues(w.key).Observe(float64(len(msgs)))
+			logger.Debug().Int("batch_size", len(msgs)).Msg("pulled batch from queue")
+
+			w.handleBatch(ctx, &logger, workerID, workerIDStr, msgs)
+		}
+	}
+}
+
+// receiveBatch falls back to a single message for backends without batch support
+func (w *Worker) receiveBatch(ctx context.Context, workerID int) ([]*queue.Message, error) {
+	if backend, ok := w.backend.(queue.BatchBackend); ok {
+		return backend.ReceiveBatch(ctx, workerID, w.batchSize)
+	}
+
+	msg, err := w.backend.Receive(ctx, workerID)
+	if err != nil {
+		return nil, err
+	}
+	return []*queue.Message{msg}, nil
+}
+
+func (w *Worker) handleBatch(ctx context.Context, logger *zerolog.Logger, workerID int, workerIDStr string, msgs []*queue.Message) {
+	batch := make([]*events.Event, 0, len(msgs))
+	received := make([]*queue.Message, 0, len(msgs))
+	var ageSum float64
+
+	for _, msg := range msgs {
+		event, err := events.UnmarshalEvent(msg.Payload)
+		if err != nil {
+			w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, "", "unmarshal_error").Inc()
+			logger.Error().Err(err).Str("payload", string(msg.Payload)).Msg("error unmarshaling event")
+			w.deadLetter(ctx, logger, workerID, msg, nil, "unmarshal_error", err)
+			continue
+		}
+
+		w.observeQueueLatency(event)
+		eventAge := time.Since(event.CreatedAt).Seconds()
+		ageSum += eventAge
+		w.metrics.EventAgeSeconds.WithLabelValues(w.key, event.Type).Observe(eventAge)
+		batch = append(batch, event)
+		received = append(received, msg)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	processStart := time.Now()
+	errs := w.batchHandler(processCtx, batch)
+	cancel()
+
+	duration := time.Since(processStart).Seconds()
+	w.metrics.BatchDuration.WithLabelValues(w.key).Observe(duration)
+	w.stats.observe(ageSum/float64(len(batch)), duration)
+
+	succeeded := make([]*queue.Message, 0, len(received))
+	for i, event := range batch {
+		var err error
+		if i < len(errs) {
+			err = errs[i]
+		}
+
+		if err != nil {
+			w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, event.Type, "handler_error").Inc()
+			w.metrics.WorkerLastError.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
+			logger.Error().Err(err).Str("event_type", event.Type).Msg("error handling event")
+			if !w.retry(ctx, logger, workerID, received[i], err) {
+				w.deadLetter(ctx, logger, workerID, received[i], event, handlerErrorType(err), err)
+			}
+			continue
+		}
+
+		w.metrics.EventsProcessedTotal.WithLabelValues(w.key, workerIDStr, event.Type, "success").Inc()
+		succeeded = append(succeeded, received[i])
+	}
+
+	if len(succeeded) > 0 {
+		w.metrics.WorkerLastSuccess.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
+		w.ackBatch(ctx, logger, workerID, succeeded)
+	}
+
+	logger.Info().
+		Int("batch_size", len(batch)).
+		Int("succeeded", len(succeeded)).
+		Float64("duration_seconds", duration).
+		Msg("batch processed")
+}
+
+// ackBatch acknowledges handled messages in one round trip where the backend supports it
+func (w *Worker) ackBatch(ctx context.Context, logger *zerolog.Logger, workerID int, msgs []*queue.Message) {
+	backend, ok := w.backend.(queue.BatchBackend)
+	if !ok {
+		for _, msg := range msgs {
+			w.ack(ctx, logger, workerID, msg)
+		}
+		return
+	}
+
+	if err := backend.AckBatch(context.WithoutCancel(ctx), workerID, msgs); err != nil {
+		logger.Error().Err(err).Int("events", len(msgs)).Msg("error acknowledging events")
+	}
+}
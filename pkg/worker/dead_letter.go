@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"alerts-worker/pkg/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event the worker gave up on, with the payload exactly as it was queued
+type DeadLetter struct {
+	ID             string     `json:"id"`
+	Queue          string     `json:"queue"`
+	Payload        string     `json:"payload"`
+	EventType      string     `json:"event_type,omitempty"`
+	ErrorType      string     `json:"error_type"`
+	Error          string     `json:"error"`
+	WorkerID       string     `json:"worker_id"`
+	Attempts       int        `json:"attempts"`
+	EventCreatedAt *time.Time `json:"event_created_at,omitempty"`
+	FailedAt       time.Time  `json:"failed_at"`
+}
+
+// DeadLetterFilter selects dead letters; empty fields match everything
+type DeadLetterFilter struct {
+	IDs           []string
+	EventType     string
+	ErrorContains string
+	FailedBefore  time.Time
+}
+
+func (f *DeadLetterFilter) matches(letter *DeadLetter) bool {
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			if id == letter.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.EventType != "" && letter.EventType != f.EventType {
+		return false
+	}
+	if f.ErrorContains != "" && !strings.Contains(letter.Error, f.ErrorContains) {
+		return false
+	}
+	if !f.FailedBefore.IsZero() && !letter.FailedAt.Before(f.FailedBefore) {
+		return false
+	}
+	return true
+}
+
+// DeadLetterQueue stores dead letters of a queue in a hash by ID, with a sorted set by
+// failure time for listing
+type DeadLetterQueue struct {
+	client  *redis.Client
+	queue   string
+	backend queue.Backend
+}
+
+// NewDeadLetterQueue takes the backend of the queue so that requeued events are added
+// the way workers receive them
+func NewDeadLetterQueue(client *redis.Client, queue string, backend queue.Backend) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		client:  client,
+		queue:   queue,
+		backend: backend,
+	}
+}
+
+func (q *DeadLetterQueue) entriesKey() string {
+	return q.queue + ":dlq"
+}
+
+func (q *DeadLetterQueue) indexKey() string {
+	return q.queue + ":dlq:index"
+}
+
+// Push stores a dead letter, assigning its ID and failure time when missing
+func (q *DeadLetterQueue) Push(ctx context.Context, letter *DeadLetter) error {
+	if letter.ID == "" {
+		letter.ID = uuid.NewString()
+	}
+	if letter.FailedAt.IsZero() {
+		letter.FailedAt = time.Now()
+	}
+	letter.Queue = q.queue
+
+	raw, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, q.entriesKey(), letter.ID, raw)
+	pipe.ZAdd(ctx, q.indexKey(), redis.Z{Score: float64(letter.FailedAt.UnixMilli()), Member: letter.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to push dead letter: %w", err)
+	}
+
+	return nil
+}
+
+func (q *DeadLetterQueue) Len(ctx context.Context) (int64, error) {
+	return q.client.ZCard(ctx, q.indexKey()).Result()
+}
+
+// List returns dead letters newest first
+func (q *DeadLetterQueue) List(ctx context.Context, offset int64, limit int64) ([]DeadLetter, error) {
+	ids, err := q.client.ZRevRange(ctx, q.indexKey(), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	return q.load(ctx, ids)
+}
+
+func (q *DeadLetterQueue) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	letters, err := q.load(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(letters) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	return &letters[0], nil
+}
+
+// Requeue publishes the payloads of matching dead letters back to the queue and removes
+// them from the dead-letter queue. A letter that fails to be removed after publishing is
+// requeued again by the next run, like any other at-least-once delivery.
+func (q *DeadLetterQueue) Requeue(ctx context.Context, filter *DeadLetterFilter) (int, error) {
+	return q.apply(ctx, filter, func(letter *DeadLetter) error {
+		return q.backend.Publish(ctx, []byte(letter.Payload))
+	})
+}
+
+// Purge deletes matching dead letters
+func (q *DeadLetterQueue) Purge(ctx context.Context, filter *DeadLetterFilter) (int, error) {
+	return q.apply(ctx, filter, nil)
+}
+
+// apply removes every matching dead letter after fn, when given, succeeded for it
+func (q *DeadLetterQueue) apply(ctx context.Context, filter *DeadLetterFilter, fn func(*DeadLetter) error) (int, error) {
+	const pageSize = 500
+
+	var processed int
+	var offset int64
+
+	for {
+		ids, err := q.client.ZRange(ctx, q.indexKey(), offset, offset+pageSize-1).Result()
+		if err != nil {
+			return processed, fmt.Errorf("failed to list dead letters: %w", err)
+		}
+		if len(ids) == 0 {
+			return processed, nil
+		}
+
+		letters, err := q.load(ctx, ids)
+		if err != nil {
+			return processed, err
+		}
+
+		var matched int64
+		for i := range letters {
+			letter := &letters[i]
+			if !filter.matches(letter) {
+				continue
+			}
+
+			if fn != nil {
+				if err := fn(letter); err != nil {
+					return processed, fmt.Errorf("failed to process dead letter %s: %w", letter.ID, err)
+				}
+			}
+
+			pipe := q.client.TxPipeline()
+			pipe.HDel(ctx, q.entriesKey(), letter.ID)
+			pipe.ZRem(ctx, q.indexKey(), letter.ID)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return processed, fmt.Errorf("failed to remove dead letter %s: %w", letter.ID, err)
+			}
+
+			processed++
+			matched++
+		}
+
+		// Removed entries shift the remaining ones down the index
+		offset += int64(len(ids)) - matched
+	}
+}
+
+func (q *DeadLetterQueue) load(ctx context.Context, ids []string) ([]DeadLetter, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	values, err := q.client.HMGet(ctx, q.entriesKey(), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dead letters: %w", err)
+	}
+
+	letters := make([]DeadLetter, 0, len(values))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(raw), &letter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dead letter %s: %w", ids[i], err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
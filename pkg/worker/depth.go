@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"alerts-worker/internal/events"
+	"context"
+	"time"
+)
+
+// startDepthSampler samples the queue depth on an interval for the QueueSize gauge and the
+// autoscaler, so that monitoring costs one command per interval whatever the worker count
+func (w *Worker) startDepthSampler(ctx context.Context) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.depthSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			w.sampleDepth(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (w *Worker) sampleDepth(ctx context.Context) {
+	size, err := w.backend.Depth(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Warn().Err(err).Msg("error sampling queue size")
+		}
+		return
+	}
+
+	w.depth.Store(size)
+	w.depthSampled.Store(true)
+	w.metrics.QueueSize.WithLabelValues(w.key).Set(float64(size))
+	if size > 0 {
+		w.logger.Debug().Int64("queue_size", size).Msg("current queue size")
+	}
+}
+
+// observeQueueLatency records how long an event waited in the queue, for events stamped
+// on publish
+func (w *Worker) observeQueueLatency(event *events.Event) {
+	if event.EnqueuedAt.IsZero() {
+		return
+	}
+	w.metrics.QueueLatency.WithLabelValues(w.key).Observe(time.Since(event.EnqueuedAt).Seconds())
+}
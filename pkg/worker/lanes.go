@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/queue"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// laneBuffer is how many events a lane holds before its receivers block
+const laneBuffer = 64
+
+// laneRetryBackoff is the delay before the first in-place retry of a failed event; it
+// grows with every attempt up to laneMaxRetryBackoff
+const (
+	laneRetryBackoff    = time.Second
+	laneMaxRetryBackoff = 30 * time.Second
+)
+
+// laneItem is a received event waiting in a lane. consumer is the receiver that got it,
+// which the backend needs to acknowledge it.
+type laneItem struct {
+	msg      *queue.Message
+	event    *events.Event
+	consumer int
+}
+
+// startLanes runs the lanes and the receivers that feed them. A receiver hands events to
+// the lanes in queue order and blocks while the lane of the next event is full, which
+// stalls all other lanes fed by it as well. LaneBacklog shows a full lane at laneBuffer.
+func (w *Worker) startLanes(ctx context.Context) {
+	w.metrics.WorkerLanes.WithLabelValues(w.key).Set(float64(w.lanes))
+
+	lanes := make([]chan laneItem, w.lanes)
+	for i := range lanes {
+		lanes[i] = make(chan laneItem, laneBuffer)
+
+		w.wg.Add(1)
+		go func(lane int) {
+			defer w.wg.Done()
+			w.runLane(ctx, lane, lanes[lane])
+		}(i)
+	}
+
+	for i := 0; i < w.workerCount; i++ {
+		w.wg.Add(1)
+		go func(receiverID int) {
+			defer w.wg.Done()
+			w.receiveIntoLanes(ctx, receiverID, lanes)
+		}(i)
+	}
+}
+
+// laneFor hashes the lane key of an event, falling back to its ID so that keyless events
+// still spread over the lanes
+func (w *Worker) laneFor(event *events.Event) int {
+	key := ""
+	if w.laneKey != nil {
+		key = w.laneKey(event)
+	}
+	if key == "" {
+		key = event.ID
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(w.lanes))
+}
+
+func (w *Worker) receiveIntoLanes(ctx context.Context, receiverID int, lanes []chan laneItem) {
+	logger := w.logger.With().Int("receiver_id", receiverID).Logger()
+	receiverIDStr := fmt.Sprintf("receiver-%d", receiverID)
+
+	w.metrics.WorkerStatus.WithLabelValues(w.key, receiverIDStr).Set(1)
+	defer w.metrics.WorkerStatus.WithLabelValues(w.key, receiverIDStr).Set(0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("receiver shutting down")
+			return
+		default:
+		}
+
+		msgs, err := w.receiveBatch(ctx, receiverID)
+		if err != nil {
+			if w.receiveFailed(ctx, &logger, receiverIDStr, err) {
+				return
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			event, err := events.UnmarshalEvent(msg.Payload)
+			if err != nil {
+				w.metrics.EventProcessingErrors.WithLabelValues(w.key, receiverIDStr, "", "unmarshal_error").Inc()
+				logger.Error().Err(err).Str("payload", string(msg.Payload)).Msg("error unmarshaling event")
+				w.deadLetter(ctx, &logger, receiverID, msg, nil, "unmarshal_error", err)
+				continue
+			}
+
+			w.observeQueueLatency(event)
+			lane := w.laneFor(event)
+			laneStr := strconv.Itoa(lane)
+			item := laneItem{msg: msg, event: event, consumer: receiverID}
+
+			select {
+			case lanes[lane] <- item:
+			default:
+				w.metrics.LaneBacklog.WithLabelValues(w.key, laneStr).Set(laneBuffer)
+				logger.Warn().Int("lane", lane).Msg("lane full, receiver blocked")
+
+				select {
+				case lanes[lane] <- item:
+				case <-ctx.Done():
+					// Unacknowledged, the rest of the batch is redelivered by a reliable backend
+					return
+				}
+			}
+			w.metrics.LaneBacklog.WithLabelValues(w.key, laneStr).Set(float64(len(lanes[lane])))
+		}
+	}
+}
+
+// runLane handles the events of one lane in order. A failed event is retried in place,
+// holding back the events behind it, until it succeeds or runs out of deliveries and is
+// dead-lettered; handing it back to the queue would let later events overtake it.
+// Events still waiting at shutdown are left unacknowledged rather than failed.
+func (w *Worker) runLane(ctx context.Context, lane int, items <-chan laneItem) {
+	logger := w.logger.With().Int("lane", lane).Logger()
+	laneStr := strconv.Itoa(lane)
+	laneIDStr := "lane-" + laneStr
+
+	for {
+		select {
+		case <-ctx.Done():
+			if len(items) > 0 {
+				logger.Warn().Int("events", len(items)).Msg("lane stopped with events left unacknowledged")
+			}
+			return
+		case item := <-items:
+			w.metrics.LaneBacklog.WithLabelValues(w.key, laneStr).Set(float64(len(items)))
+			w.metrics.WorkerBusy.WithLabelValues(w.key, laneIDStr).Set(1)
+
+			w.handleInLane(ctx, &logger, laneIDStr, item)
+
+			w.metrics.WorkerBusy.WithLabelValues(w.key, laneIDStr).Set(0)
+		}
+	}
+}
+
+// handleInLane handles an event, retrying it with a growing backoff while it fails
+func (w *Worker) handleInLane(ctx context.Context, logger *zerolog.Logger, laneIDStr string, item laneItem) {
+	backoff := laneRetryBackoff
+
+	for {
+		err := w.runHandler(ctx, logger, laneIDStr, item.event)
+		if err == nil {
+			w.ack(ctx, logger, item.consumer, item.msg)
+			return
+		}
+		if IsPermanent(err) || item.msg.Attempts >= w.maxDeliveries {
+			w.deadLetter(ctx, logger, item.consumer, item.msg, item.event, handlerErrorType(err), err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			// Unacknowledged, the event is redelivered by a reliable backend
+			return
+		case <-time.After(backoff):
+		}
+
+		item.msg.Attempts++
+		backoff = min(backoff*2, laneMaxRetryBackoff)
+	}
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"alerts-worker/internal/events"
 	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/queue"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,16 +12,72 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 )
 
+// permanentError marks a handler error that no retry can fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as permanent, so that the worker dead-letters the event right away
+// instead of delivering it again
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked by Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
 type WorkerOptions struct {
 	WorkerCount int
+
+	// InstanceID names this process in dead letters; defaults to hostname and pid
+	InstanceID string
+	// MaxDeliveries caps how often a failing event is re-queued. Events failing with an
+	// error marked Permanent are dead-lettered at once.
+	MaxDeliveries int
+	// BatchHandler, when set, replaces the handler: every worker receives up to BatchSize
+	// events at once and hands them over together
+	BatchHandler BatchHandler
+	BatchSize    int
+	// Lanes, when set, routes every event to one of Lanes ordered lanes by a hash of
+	// LaneKey, so that events with the same key are handled one after another while
+	// different keys run in parallel. WorkerCount is then the number of receivers feeding
+	// the lanes; a single receiver keeps the queue order. A failed event is retried in
+	// its lane, and a full lane blocks its receiver and with it every other lane it
+	// feeds; LaneBacklog shows which lane is full. Ignored with a BatchHandler.
+	Lanes   int
+	LaneKey func(*events.Event) string
+	// DepthSampleInterval is how often the queue depth is sampled for the QueueSize gauge
+	// and the autoscaler
+	DepthSampleInterval time.Duration
+	// Autoscale, when set, replaces WorkerCount with a pool sized between its MinWorkers
+	// and MaxWorkers. Ignored with Lanes.
+	Autoscale *AutoscaleOptions
+	// Logger receives the worker logs; without it they are discarded
+	Logger *zerolog.Logger
+	// DeadLetters receives events the worker gives up on. Without it they are dropped
+	// after being logged.
+	DeadLetters *DeadLetterQueue
 }
 
 type Worker struct {
-	client      *redis.Client
+	backend     queue.Backend
 	key         string
 	handler     func(context.Context, *events.Event) error
 	logger      zerolog.Logger
@@ -30,27 +87,74 @@ type Worker struct {
 	running     atomic.Bool
 	shutdownCtx context.Context
 	cancelFunc  context.CancelFunc
+
+	deadLetters   *DeadLetterQueue
+	instanceID    string
+	maxDeliveries int
+
+	batchHandler BatchHandler
+	batchSize    int
+
+	lanes   int
+	laneKey func(*events.Event) string
+
+	autoscale *AutoscaleOptions
+	pool      []chan struct{}
+	stats     processingStats
+
+	depthSampleInterval time.Duration
+	depth               atomic.Int64
+	depthSampled        atomic.Bool
 }
 
-func NewWorker(client *redis.Client, key string, handler func(context.Context, *events.Event) error, opts *WorkerOptions, metrics *metrics.WorkerMetrics) *Worker {
+// NewWorker creates a worker consuming backend; key names the queue in metrics and logs
+func NewWorker(backend queue.Backend, key string, handler func(context.Context, *events.Event) error, opts *WorkerOptions, metrics *metrics.WorkerMetrics) *Worker {
 	workerCount := opts.WorkerCount
 	if workerCount <= 0 {
 		workerCount = 1
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Worker{
-		client:      client,
-		key:         key,
-		handler:     handler,
-		workerCount: workerCount,
-		metrics:     metrics,
-		shutdownCtx: ctx,
-		cancelFunc:  cancel,
+	w := &Worker{
+		backend:       backend,
+		key:           key,
+		handler:       handler,
+		workerCount:   workerCount,
+		metrics:       metrics,
+		shutdownCtx:   ctx,
+		cancelFunc:    cancel,
+		deadLetters:   opts.DeadLetters,
+		instanceID:    opts.InstanceID,
+		maxDeliveries: opts.MaxDeliveries,
+		batchHandler:  opts.BatchHandler,
+		batchSize:     opts.BatchSize,
+		lanes:         opts.Lanes,
+		laneKey:       opts.LaneKey,
+		autoscale:     opts.Autoscale,
+
+		depthSampleInterval: opts.DepthSampleInterval,
+	}
+
+	if opts.Logger != nil {
+		w.logger = *opts.Logger
+	}
+	if w.instanceID == "" {
+		w.instanceID = queue.DefaultInstanceID()
+	}
+	if w.maxDeliveries <= 0 {
+		w.maxDeliveries = 5
 	}
+	if w.batchSize <= 0 {
+		w.batchSize = 100
+	}
+	if w.depthSampleInterval <= 0 {
+		w.depthSampleInterval = 5 * time.Second
+	}
+
+	return w
 }
 
-func (w *Worker) process(ctx context.Context, workerID int) error {
+func (w *Worker) process(ctx context.Context, workerID int, stop <-chan struct{}) error {
 	logger := w.logger.With().Int("worker_id", workerID).Logger()
 	workerIDStr := fmt.Sprintf("%d", workerID)
 
@@ -63,108 +167,136 @@ func (w *Worker) process(ctx context.Context, workerID int) error {
 		case <-ctx.Done():
 			logger.Info().Msg("worker shutting down")
 			return nil
+		case <-stop:
+			logger.Info().Msg("worker retired by autoscaler")
+			return nil
 		default:
-			// Update queue size and log it for visibility
-			if size, err := w.client.LLen(ctx, w.key).Result(); err == nil {
-				w.metrics.QueueSize.WithLabelValues(w.key).Set(float64(size))
-				if size > 0 {
-					logger.Debug().Int64("queue_size", size).Msg("current queue size")
-				}
-			}
-
 			// Set worker as idle
 			w.metrics.WorkerBusy.WithLabelValues(w.key, workerIDStr).Set(0)
 
-			// Create a separate context with timeout for BRPop
-			brpopCtx, brpopCancel := context.WithTimeout(ctx, 10*time.Second)
-
 			// Try to get an event from the queue
-			result, err := w.client.BRPop(brpopCtx, 5*time.Second, w.key).Result()
-			brpopCancel() // Cancel the BRPop context immediately after the operation
+			msg, err := w.backend.Receive(ctx, workerID)
 
 			if err != nil {
-				if errors.Is(err, redis.Nil) {
-					// This is normal when the queue is empty
-					logger.Debug().Msg("queue empty, waiting for items")
-					continue
-				}
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					// Check if the parent context was canceled
-					select {
-					case <-ctx.Done():
-						logger.Info().Msg("parent context canceled")
-						return nil
-					default:
-						// It was just the BRPop context timing out
-						logger.Debug().Msg("brpop context timed out")
-						continue
-					}
+				if w.receiveFailed(ctx, &logger, workerIDStr, err) {
+					return nil
 				}
-				w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, "", "redis_error").Inc()
-				logger.Error().Err(err).Msg("error getting event from queue")
-				time.Sleep(1 * time.Second) // Add backoff on Redis errors
 				continue
 			}
 
+			payload := string(msg.Payload)
+
 			// Log successful pull for debugging
-			logger.Debug().Str("payload_size", fmt.Sprintf("%d bytes", len(result[1]))).Msg("pulled item from queue")
+			logger.Debug().Str("payload_size", fmt.Sprintf("%d bytes", len(payload))).Msg("pulled item from queue")
 
 			// Set worker as busy
 			w.metrics.WorkerBusy.WithLabelValues(w.key, workerIDStr).Set(1)
 
 			// First unmarshal to get the basic event details
 			var baseEvent events.Event
-			if err := json.Unmarshal([]byte(result[1]), &baseEvent); err != nil {
+			if err := json.Unmarshal([]byte(payload), &baseEvent); err != nil {
 				w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, "", "unmarshal_error").Inc()
-				logger.Error().Err(err).Str("payload", result[1]).Msg("error unmarshaling event")
+				logger.Error().Err(err).Str("payload", payload).Msg("error unmarshaling event")
+				w.deadLetter(ctx, &logger, workerID, msg, nil, "unmarshal_error", err)
 				continue
 			}
 
-			// Log event information
-			logger.Info().
-				Str("event_type", baseEvent.Type).
-				Time("created_at", baseEvent.CreatedAt).
-				Msg("processing event")
-
-			// Record event age
-			eventAge := time.Since(baseEvent.CreatedAt).Seconds()
-			w.metrics.EventAgeSeconds.WithLabelValues(w.key, baseEvent.Type).Observe(eventAge)
-
-			// Apply a timeout to the handler
-			processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-
 			// Use the custom unmarshaler to get the appropriate event type
-			event, err := events.UnmarshalEvent([]byte(result[1]))
+			event, err := events.UnmarshalEvent([]byte(payload))
 			if err != nil {
-				cancel()
 				w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, baseEvent.Type, "unmarshal_error").Inc()
-				logger.Error().Err(err).Str("payload", result[1]).Msg("error unmarshaling specific event type")
+				logger.Error().Err(err).Str("payload", payload).Msg("error unmarshaling specific event type")
+				w.deadLetter(ctx, &logger, workerID, msg, &baseEvent, "unmarshal_error", err)
 				continue
 			}
 
-			// Process the event and measure duration
-			processStart := time.Now()
-			if err := w.handler(processCtx, event); err != nil {
-				cancel() // Cancel the context immediately
-				w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, baseEvent.Type, "handler_error").Inc()
-				w.metrics.WorkerLastError.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
-				logger.Error().Err(err).Msg("error handling event")
-				continue
-			}
+			w.observeQueueLatency(event)
+			w.handleEvent(ctx, &logger, workerID, workerIDStr, msg, event)
+		}
+	}
+}
 
-			// Record success metrics
-			duration := time.Since(processStart).Seconds()
-			w.metrics.EventProcessingDuration.WithLabelValues(w.key, workerIDStr, baseEvent.Type).Observe(duration)
-			w.metrics.EventsProcessedTotal.WithLabelValues(w.key, workerIDStr, baseEvent.Type, "success").Inc()
-			w.metrics.WorkerLastSuccess.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
-			logger.Info().
-				Str("event_type", baseEvent.Type).
-				Float64("duration_seconds", duration).
-				Msg("event processed successfully")
-
-			cancel() // Cancel the context after successful processing
+// handleEvent runs the handler on a decoded event and acknowledges, retries or
+// dead-letters its message
+func (w *Worker) handleEvent(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	workerID int,
+	workerIDStr string,
+	msg *queue.Message,
+	event *events.Event) {
+
+	if err := w.runHandler(ctx, logger, workerIDStr, event); err != nil {
+		if !w.retry(ctx, logger, workerID, msg, err) {
+			w.deadLetter(ctx, logger, workerID, msg, event, handlerErrorType(err), err)
 		}
+		return
 	}
+
+	w.ack(ctx, logger, workerID, msg)
+}
+
+// runHandler runs the handler on event with a timeout and records the outcome
+func (w *Worker) runHandler(ctx context.Context, logger *zerolog.Logger, workerIDStr string, event *events.Event) error {
+	// Log event information
+	logger.Info().
+		Str("event_type", event.Type).
+		Time("created_at", event.CreatedAt).
+		Msg("processing event")
+
+	// Record event age
+	eventAge := time.Since(event.CreatedAt).Seconds()
+	w.metrics.EventAgeSeconds.WithLabelValues(w.key, event.Type).Observe(eventAge)
+
+	// Apply a timeout to the handler
+	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Process the event and measure duration
+	processStart := time.Now()
+	if err := w.handler(processCtx, event); err != nil {
+		w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, event.Type, "handler_error").Inc()
+		w.metrics.WorkerLastError.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
+		logger.Error().Err(err).Msg("error handling event")
+		return err
+	}
+
+	// Record success metrics
+	duration := time.Since(processStart).Seconds()
+	w.stats.observe(eventAge, duration)
+	w.metrics.EventProcessingDuration.WithLabelValues(w.key, workerIDStr, event.Type).Observe(duration)
+	w.metrics.EventsProcessedTotal.WithLabelValues(w.key, workerIDStr, event.Type, "success").Inc()
+	w.metrics.WorkerLastSuccess.WithLabelValues(w.key, workerIDStr).Set(float64(time.Now().Unix()))
+	logger.Info().
+		Str("event_type", event.Type).
+		Float64("duration_seconds", duration).
+		Msg("event processed successfully")
+
+	return nil
+}
+
+func (w *Worker) receiveFailed(ctx context.Context, logger *zerolog.Logger, workerIDStr string, err error) bool {
+	if errors.Is(err, queue.ErrNoMessage) {
+		// This is normal when the queue is empty
+		logger.Debug().Msg("queue empty, waiting for items")
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// Check if the parent context was canceled
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("parent context canceled")
+			return true
+		default:
+			// It was just the receive context timing out
+			logger.Debug().Msg("receive context timed out")
+			return false
+		}
+	}
+	w.metrics.EventProcessingErrors.WithLabelValues(w.key, workerIDStr, "", "backend_error").Inc()
+	logger.Error().Err(err).Msg("error getting event from queue")
+	time.Sleep(1 * time.Second) // Add backoff on backend errors
+	return false
 }
 
 func (w *Worker) Start(ctx context.Context) error {
@@ -172,17 +304,42 @@ func (w *Worker) Start(ctx context.Context) error {
 		return errors.New("already running")
 	}
 
+	if starter, ok := w.backend.(queue.Starter); ok {
+		starter.Start(ctx)
+	}
+
+	w.startDepthSampler(ctx)
+
+	if w.lanes > 0 && w.batchHandler == nil {
+		w.startLanes(ctx)
+		return nil
+	}
+
+	if w.autoscale != nil {
+		w.startAutoscaling(ctx)
+		return nil
+	}
+
 	for i := 0; i < w.workerCount; i++ {
-		w.wg.Add(1)
-		go func(workerID int) {
-			defer w.wg.Done()
-			_ = w.process(ctx, workerID)
-		}(i)
+		w.startWorker(ctx, i, nil)
 	}
 
 	return nil
 }
 
+// startWorker runs a worker until ctx is done or stop is closed
+func (w *Worker) startWorker(ctx context.Context, workerID int, stop <-chan struct{}) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if w.batchHandler != nil {
+			_ = w.processBatch(ctx, workerID, stop)
+			return
+		}
+		_ = w.process(ctx, workerID, stop)
+	}()
+}
+
 func (w *Worker) Stop(timeout time.Duration) {
 	w.cancelFunc()
 	done := make(chan struct{})
@@ -197,3 +354,74 @@ func (w *Worker) Stop(timeout time.Duration) {
 		w.logger.Warn().Msg("workers forced to stop due to timeout")
 	}
 }
+
+// ack acknowledges a handled message, even when shutting down, or it would be handled twice
+func (w *Worker) ack(ctx context.Context, logger *zerolog.Logger, workerID int, msg *queue.Message) {
+	if err := w.backend.Ack(context.WithoutCancel(ctx), workerID, msg); err != nil {
+		logger.Error().Err(err).Msg("error acknowledging event")
+	}
+}
+
+// retry hands a failed message back to the backend unless its error is permanent or it
+// used up MaxDeliveries, and reports false when the event has to be dead-lettered instead
+func (w *Worker) retry(ctx context.Context, logger *zerolog.Logger, workerID int, msg *queue.Message, err error) bool {
+	if IsPermanent(err) || msg.Attempts >= w.maxDeliveries {
+		return false
+	}
+
+	retried, nackErr := w.backend.Nack(context.WithoutCancel(ctx), workerID, msg)
+	if nackErr != nil {
+		logger.Error().Err(nackErr).Msg("error re-queueing event")
+	}
+	return retried
+}
+
+// handlerErrorType is the dead letter error type of a failed handler
+func handlerErrorType(err error) string {
+	if IsPermanent(err) {
+		return "permanent_error"
+	}
+	return "handler_error"
+}
+
+// deadLetter moves an event the worker gives up on to the dead-letter queue
+func (w *Worker) deadLetter(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	workerID int,
+	msg *queue.Message,
+	event *events.Event,
+	errorType string,
+	cause error) {
+
+	letter := &DeadLetter{
+		Payload:   string(msg.Payload),
+		ErrorType: errorType,
+		Error:     cause.Error(),
+		WorkerID:  fmt.Sprintf("%s:%d", w.instanceID, workerID),
+		Attempts:  msg.Attempts,
+	}
+	if event != nil {
+		letter.EventType = event.Type
+		if !event.CreatedAt.IsZero() {
+			letter.EventCreatedAt = &event.CreatedAt
+		}
+	}
+
+	if w.deadLetters == nil {
+		logger.Error().Str("payload", letter.Payload).Str("error_type", errorType).Msg("dropping event without a dead-letter queue")
+		w.ack(ctx, logger, workerID, msg)
+		return
+	}
+
+	if err := w.deadLetters.Push(context.WithoutCancel(ctx), letter); err != nil {
+		// Left unacknowledged, a reliable backend delivers the event again later
+		logger.Error().Err(err).Str("payload", letter.Payload).Msg("error dead-lettering event")
+		return
+	}
+
+	w.metrics.EventsDeadLettered.WithLabelValues(w.key, letter.EventType, errorType).Inc()
+	logger.Warn().Str("dead_letter_id", letter.ID).Str("error_type", errorType).Msg("event moved to dead-letter queue")
+
+	w.ack(ctx, logger, workerID, msg)
+}
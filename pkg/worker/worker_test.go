@@ -0,0 +1,419 @@
+package worker
+
+import (
+	"alerts-worker/internal/events"
+	"alerts-worker/pkg/metrics"
+	"alerts-worker/pkg/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The metrics register with the default registry, so they can only be created once
+var testMetrics = metrics.InitWorkerMetrics()
+
+// recordingBackend is a MemoryBackend that records which messages were acknowledged and
+// handed back
+type recordingBackend struct {
+	*queue.MemoryBackend
+
+	mu     sync.Mutex
+	acked  []string
+	nacked []string
+}
+
+func newRecordingBackend() *recordingBackend {
+	return &recordingBackend{MemoryBackend: queue.NewMemoryBackend()}
+}
+
+func (b *recordingBackend) Ack(ctx context.Context, consumer int, msg *queue.Message) error {
+	b.mu.Lock()
+	b.acked = append(b.acked, msg.ID)
+	b.mu.Unlock()
+	return b.MemoryBackend.Ack(ctx, consumer, msg)
+}
+
+func (b *recordingBackend) AckBatch(ctx context.Context, consumer int, msgs []*queue.Message) error {
+	b.mu.Lock()
+	for _, msg := range msgs {
+		b.acked = append(b.acked, msg.ID)
+	}
+	b.mu.Unlock()
+	return b.MemoryBackend.AckBatch(ctx, consumer, msgs)
+}
+
+func (b *recordingBackend) Nack(ctx context.Context, consumer int, msg *queue.Message) (bool, error) {
+	b.mu.Lock()
+	b.nacked = append(b.nacked, msg.ID)
+	b.mu.Unlock()
+	return b.MemoryBackend.Nack(ctx, consumer, msg)
+}
+
+func (b *recordingBackend) counts() (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.acked), len(b.nacked)
+}
+
+func publishEvent(t *testing.T, backend queue.Backend, id string, symbol string) {
+	t.Helper()
+
+	payload, err := json.Marshal(&events.Event{
+		ID:        id,
+		Type:      "test",
+		Data:      map[string]string{"symbol": symbol},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Publish(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symbolOf(event *events.Event) string {
+	data, _ := event.Data.(map[string]interface{})
+	symbol, _ := data["symbol"].(string)
+	return symbol
+}
+
+// startWorker runs a worker until the test ends
+func startWorker(t *testing.T, backend queue.Backend, handler func(context.Context, *events.Event) error, opts *WorkerOptions) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWorker(backend, t.Name(), handler, opts, testMetrics)
+	if err := w.Start(ctx); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		w.Stop(5 * time.Second)
+	})
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkerAcksHandledEvents(t *testing.T) {
+	backend := newRecordingBackend()
+	for i := 0; i < 3; i++ {
+		publishEvent(t, backend, fmt.Sprintf("event-%d", i), "BTCUSDT")
+	}
+
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		return nil
+	}, &WorkerOptions{WorkerCount: 2})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 3
+	})
+	if _, nacked := backend.counts(); nacked != 0 {
+		t.Fatalf("expected no retries, got %d", nacked)
+	}
+}
+
+func TestWorkerRetriesFailedEvents(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "event", "BTCUSDT")
+
+	var mu sync.Mutex
+	calls := 0
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("transient")
+		}
+		return nil
+	}, &WorkerOptions{WorkerCount: 1, MaxDeliveries: 3})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 1
+	})
+	if _, nacked := backend.counts(); nacked != 1 {
+		t.Fatalf("expected one retry, got %d", nacked)
+	}
+}
+
+func TestWorkerDropsEventsAfterMaxDeliveries(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "event", "BTCUSDT")
+
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		return errors.New("permanent")
+	}, &WorkerOptions{WorkerCount: 1, MaxDeliveries: 3})
+
+	// Without a dead-letter queue the event is acknowledged once it is given up on
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 1
+	})
+	if _, nacked := backend.counts(); nacked != 2 {
+		t.Fatalf("expected two retries before giving up, got %d", nacked)
+	}
+}
+
+func TestWorkerKeepsEventsTheDeadLetterQueueRejects(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "event", "BTCUSDT")
+
+	// Nothing listens there, so every push fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+
+	handled := make(chan struct{}, 1)
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		select {
+		case handled <- struct{}{}:
+		default:
+		}
+		return errors.New("permanent")
+	}, &WorkerOptions{
+		WorkerCount:   1,
+		MaxDeliveries: 1,
+		DeadLetters:   NewDeadLetterQueue(client, t.Name(), backend),
+	})
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not handled")
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// Left unacknowledged, a reliable backend would deliver the event again
+	if acked, nacked := backend.counts(); acked != 0 || nacked != 0 {
+		t.Fatalf("expected the event to stay in flight, got %d acks and %d retries", acked, nacked)
+	}
+}
+
+func TestWorkerDeadLettersUndecodableEvents(t *testing.T) {
+	backend := newRecordingBackend()
+	if err := backend.Publish(context.Background(), []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		t.Error("handler called for an undecodable event")
+		return nil
+	}, &WorkerOptions{WorkerCount: 1})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 1
+	})
+	if _, nacked := backend.counts(); nacked != 0 {
+		t.Fatalf("expected no retries of an undecodable event, got %d", nacked)
+	}
+}
+
+func TestLanesKeepTheOrderPerKey(t *testing.T) {
+	backend := newRecordingBackend()
+	for i := 0; i < 5; i++ {
+		publishEvent(t, backend, fmt.Sprintf("btc-%d", i), "BTCUSDT")
+		publishEvent(t, backend, fmt.Sprintf("eth-%d", i), "ETHUSDT")
+	}
+
+	var mu sync.Mutex
+	handled := make(map[string][]string)
+	failed := false
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		symbol := symbolOf(event)
+		handled[symbol] = append(handled[symbol], event.ID)
+		// The first event fails once; the events behind it have to wait for its retry
+		if event.ID == "btc-0" && !failed {
+			failed = true
+			return errors.New("transient")
+		}
+		return nil
+	}, &WorkerOptions{
+		WorkerCount:   1,
+		MaxDeliveries: 3,
+		Lanes:         4,
+		LaneKey:       symbolOf,
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 10
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	wantBTC := []string{"btc-0", "btc-0", "btc-1", "btc-2", "btc-3", "btc-4"}
+	if fmt.Sprint(handled["BTCUSDT"]) != fmt.Sprint(wantBTC) {
+		t.Fatalf("unexpected BTCUSDT order %v, want %v", handled["BTCUSDT"], wantBTC)
+	}
+	wantETH := []string{"eth-0", "eth-1", "eth-2", "eth-3", "eth-4"}
+	if fmt.Sprint(handled["ETHUSDT"]) != fmt.Sprint(wantETH) {
+		t.Fatalf("unexpected ETHUSDT order %v, want %v", handled["ETHUSDT"], wantETH)
+	}
+	if _, nacked := backend.counts(); nacked != 0 {
+		t.Fatalf("expected lanes to retry in place, got %d retries through the queue", nacked)
+	}
+}
+
+func TestLanesGiveUpAfterMaxDeliveries(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "btc-0", "BTCUSDT")
+	publishEvent(t, backend, "btc-1", "BTCUSDT")
+
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts[event.ID]++
+		if event.ID == "btc-0" {
+			return errors.New("permanent")
+		}
+		return nil
+	}, &WorkerOptions{
+		WorkerCount:   1,
+		MaxDeliveries: 2,
+		Lanes:         2,
+		LaneKey:       symbolOf,
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 2
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["btc-0"] != 2 || attempts["btc-1"] != 1 {
+		t.Fatalf("unexpected attempts %v", attempts)
+	}
+}
+
+func TestBatchAcksSucceededAndRetriesFailedEvents(t *testing.T) {
+	backend := newRecordingBackend()
+	for i := 0; i < 4; i++ {
+		publishEvent(t, backend, fmt.Sprintf("event-%d", i), "BTCUSDT")
+	}
+
+	var mu sync.Mutex
+	batches := 0
+	handler := func(ctx context.Context, batch []*events.Event) []error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches++
+
+		// The second event fails the first time it is handed over
+		errs := make([]error, len(batch))
+		for i, event := range batch {
+			if event.ID == "event-1" && batches == 1 {
+				errs[i] = errors.New("transient")
+			}
+		}
+		return errs
+	}
+
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		t.Error("single event handler called in batch mode")
+		return nil
+	}, &WorkerOptions{
+		WorkerCount:   1,
+		MaxDeliveries: 3,
+		BatchSize:     10,
+		BatchHandler:  handler,
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 4
+	})
+	if _, nacked := backend.counts(); nacked != 1 {
+		t.Fatalf("expected one retry, got %d", nacked)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if batches != 2 {
+		t.Fatalf("expected the retried event in a second batch, got %d batches", batches)
+	}
+}
+
+func TestBatchDropsEventsAfterMaxDeliveries(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "event", "BTCUSDT")
+
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		return nil
+	}, &WorkerOptions{
+		WorkerCount:   1,
+		MaxDeliveries: 2,
+		BatchSize:     10,
+		BatchHandler: func(ctx context.Context, batch []*events.Event) []error {
+			errs := make([]error, len(batch))
+			for i := range errs {
+				errs[i] = errors.New("permanent")
+			}
+			return errs
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 1
+	})
+	if _, nacked := backend.counts(); nacked != 1 {
+		t.Fatalf("expected one retry before giving up, got %d", nacked)
+	}
+}
+
+func TestWorkerDeadLettersPermanentErrorsRightAway(t *testing.T) {
+	backend := newRecordingBackend()
+	publishEvent(t, backend, "event", "BTCUSDT")
+
+	var mu sync.Mutex
+	calls := 0
+	startWorker(t, backend, func(ctx context.Context, event *events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return Permanent(errors.New("unknown event type"))
+	}, &WorkerOptions{WorkerCount: 1, MaxDeliveries: 5})
+
+	waitFor(t, 5*time.Second, func() bool {
+		acked, _ := backend.counts()
+		return acked == 1
+	})
+	if _, nacked := backend.counts(); nacked != 0 {
+		t.Fatalf("expected no retries of a permanent error, got %d", nacked)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}